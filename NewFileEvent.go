@@ -1,6 +1,15 @@
 package server
 
 // event: create,write,remove,rename
+// With LiveReload, asset files (html, css, js, wasm) only reload the browsers,
+// unless the external server embeds them: then it is rebuilt, which reloads too.
 func (h *ServerHandler) NewFileEvent(fileName, extension, filePath, event string) error {
-	return h.strategy.HandleFileEvent(fileName, extension, filePath, event)
+	strategy := h.current()
+	if h.LiveReload && isReloadAsset(extension) {
+		if ext, ok := strategy.(*externalStrategy); !ok || !ext.builtFrom(filePath) {
+			h.Reload()
+			return nil
+		}
+	}
+	return strategy.HandleFileEvent(fileName, extension, filePath, event)
 }
@@ -0,0 +1,28 @@
+package server
+
+import "net/http"
+
+// ReplaceRoutes sets Config.Routes and, in In-Memory mode, swaps a mux built from
+// them behind the running server. Unlike RestartServer the listener stays open,
+// so keep-alive connections and in-flight requests are not interrupted.
+// In External mode the routes are only stored for the next switch to In-Memory.
+func (h *ServerHandler) ReplaceRoutes(routes []func(*http.ServeMux)) error {
+	if s, ok := h.current().(*inMemoryStrategy); ok {
+		return s.swapRoutes(routes)
+	}
+	h.setRoutes(routes)
+	return nil
+}
+
+// routes returns Config.Routes, which ReplaceRoutes may set from another goroutine.
+func (h *ServerHandler) routes() []func(*http.ServeMux) {
+	h.routesMu.Lock()
+	defer h.routesMu.Unlock()
+	return h.Routes
+}
+
+func (h *ServerHandler) setRoutes(routes []func(*http.ServeMux)) {
+	h.routesMu.Lock()
+	h.Routes = routes
+	h.routesMu.Unlock()
+}
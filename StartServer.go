@@ -6,7 +6,7 @@ import (
 
 // Start initiates the server using the current strategy (In-Memory or External)
 func (h *ServerHandler) StartServer(wg *sync.WaitGroup) {
-	if err := h.strategy.Start(wg); err != nil {
+	if err := h.current().Start(wg); err != nil {
 		h.Logger("StartServer error:", err)
 	}
 }
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// BuildStatus describes the external server build. While a rebuild fails the
+// previous binary keeps serving and the status is stale.
+type BuildStatus struct {
+	Running   bool          // a binary is serving
+	BuiltAt   time.Time     // when the running binary was compiled
+	BuildTook time.Duration // how long compiling the running binary took
+	LastError error         // why the latest compile or start failed, nil if it succeeded
+	FailedAt  time.Time     // when LastError happened
+
+	Crashes   int          // consecutive unexpected exits of the server
+	LastCrash *CrashReport // nil if the server never crashed since the last build
+	CrashLoop bool         // restarts stopped after Config.CrashRestartLimit crashes
+}
+
+// Stale reports whether the running binary is older than the latest (failed) build.
+func (b BuildStatus) Stale() bool {
+	return b.Running && b.LastError != nil
+}
+
+func (b BuildStatus) String() string {
+	switch {
+	case b.CrashLoop:
+		return fmt.Sprintf("crash loop, gave up after %d restarts, last %s", b.Crashes-1, b.LastCrash)
+	case b.Stale():
+		return "running stale build from " + b.BuiltAt.Format(time.TimeOnly) + ", latest build failed at " + b.FailedAt.Format(time.TimeOnly)
+	case b.Running:
+		return "running build from " + b.BuiltAt.Format(time.TimeOnly)
+	case b.LastError != nil:
+		return "not running, latest build failed at " + b.FailedAt.Format(time.TimeOnly)
+	default:
+		return "not running"
+	}
+}
+
+// BuildStatus reports the state of the external server build.
+// In In-Memory mode nothing is built and the zero value is returned.
+func (h *ServerHandler) BuildStatus() BuildStatus {
+	if s, ok := h.current().(*externalStrategy); ok {
+		return s.buildStatus()
+	}
+	return BuildStatus{}
+}
+
+func (s *externalStrategy) buildStatus() BuildStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := s.status
+	status.Running = s.process.Running()
+	return status
+}
+
+// lastGoodPath is where a copy of the last binary that passed readiness is kept,
+// so it can be put back when a newer build fails to start.
+func (s *externalStrategy) lastGoodPath() string {
+	return filepath.Join(s.handler.AppRootDir, s.handler.OutputDir, s.goCompiler.MainOutputFileNameWithExtension()+".prev")
+}
+
+func (s *externalStrategy) binaryPath() string {
+	return filepath.Join(s.handler.AppRootDir, s.handler.OutputDir, s.goCompiler.MainOutputFileNameWithExtension())
+}
+
+// saveLastGood keeps the binary on disk as the last good one.
+func (s *externalStrategy) saveLastGood() {
+	if err := linkOrCopy(s.binaryPath(), s.lastGoodPath()); err != nil {
+		s.handler.Logger("Saving last good build:", err)
+		return
+	}
+	s.goodID = s.buildID
+}
+
+// restoreLastGood puts the last good binary back in place of a build that did
+// not start. It reports false if there is none.
+func (s *externalStrategy) restoreLastGood() bool {
+	if _, err := os.Stat(s.lastGoodPath()); err != nil {
+		return false
+	}
+	if err := linkOrCopy(s.lastGoodPath(), s.binaryPath()); err != nil {
+		s.handler.Logger("Restoring last good build:", err)
+		return false
+	}
+	s.buildID = s.goodID
+	return true
+}
+
+// linkOrCopy replaces dst with src, hard linking when the file system allows it.
+func linkOrCopy(src, dst string) error {
+	if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if os.Link(src, dst) == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestFailedRebuildKeepsPreviousServer(t *testing.T) {
+	tmp := t.TempDir()
+	sourceDir := filepath.Join(tmp, "src", "app")
+	if err := os.MkdirAll(sourceDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	port := freePort(t)
+	mainFile := filepath.Join(sourceDir, "main.go")
+	good := fmt.Sprintf(fixedPortServer, port)
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(mainFile, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(good)
+
+	var mu sync.Mutex
+	var logs []string
+	h := New(&Config{
+		AppRootDir:     tmp,
+		SourceDir:      "src/app",
+		OutputDir:      "deploy",
+		AppPort:        port,
+		ProxyOnRebuild: true,
+		ExitChan:       make(chan bool, 1),
+	})
+	h.SetLog(func(messages ...any) {
+		mu.Lock()
+		logs = append(logs, fmt.Sprint(messages...))
+		mu.Unlock()
+	})
+	h.SetExternalServerMode(true)
+	defer h.strategy.Stop()
+
+	url := "http://127.0.0.1:" + port
+	if st := h.BuildStatus(); !st.Running || st.Stale() || st.BuiltAt.IsZero() {
+		t.Fatalf("unexpected status after start: %+v", st)
+	}
+
+	// Compile error: the running server is left alone
+	write(strings.Replace(good, "fmt.Fprint(w", "fmt.Fprintz(w", 1))
+	if err := h.NewFileEvent("main.go", ".go", mainFile, "write"); err == nil {
+		t.Fatal("expected compile error")
+	}
+	if _, body := getBody(t, url); body != "APP_OK" {
+		t.Fatalf("expected previous server to keep serving, got %q", body)
+	}
+	st := h.BuildStatus()
+	if !st.Stale() || !strings.Contains(st.String(), "running stale build from") {
+		t.Errorf("expected stale status, got %q", st)
+	}
+	mu.Lock()
+	logged := strings.Contains(strings.Join(logs, "\n"), "running stale build")
+	mu.Unlock()
+	if !logged {
+		t.Errorf("expected stale build to be logged, got: %v", logs)
+	}
+
+	// Compiles but exits on startup: the previous process is left alone
+	pid := h.Status().PID
+	write(strings.Replace(good, "func main() {", "func main() {\n\tpanic(\"boom\")", 1))
+	if err := h.NewFileEvent("main.go", ".go", mainFile, "write"); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("expected readiness error with stderr, got %v", err)
+	}
+	if _, body := getBody(t, url); body != "APP_OK" {
+		t.Fatalf("expected previous server to keep serving, got %q", body)
+	}
+	if got := h.Status().PID; got != pid {
+		t.Errorf("expected previous process %d to keep running, got %d", pid, got)
+	}
+	if !h.BuildStatus().Stale() {
+		t.Error("expected stale status after failed start")
+	}
+
+	// Back to the sources of the running build
+	write(good)
+	if err := h.NewFileEvent("main.go", ".go", mainFile, "write"); err != nil {
+		t.Fatalf("NewFileEvent: %v", err)
+	}
+	if st := h.BuildStatus(); st.Stale() || !st.Running {
+		t.Errorf("expected up to date status, got %q", st)
+	}
+}
+
+func TestFailedStartRestoresPreviousBuild(t *testing.T) {
+	tmp := t.TempDir()
+	sourceDir := filepath.Join(tmp, "src", "app")
+	if err := os.MkdirAll(sourceDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	port := freePort(t)
+	mainFile := filepath.Join(sourceDir, "main.go")
+	good := fmt.Sprintf(fixedPortServer, port)
+	if err := os.WriteFile(mainFile, []byte(good), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	h := New(&Config{
+		AppRootDir: tmp,
+		SourceDir:  "src/app",
+		OutputDir:  "deploy",
+		AppPort:    port,
+		ExitChan:   make(chan bool, 1),
+	})
+	h.SetExternalServerMode(true)
+	defer h.strategy.Stop()
+
+	url := "http://127.0.0.1:" + port
+	getBody(t, url)
+
+	// Without ProxyMode the previous process releases AppPort first and the
+	// last good binary is put back once the new one fails
+	broken := strings.Replace(good, "func main() {", "func main() {\n\tpanic(\"boom\")", 1)
+	if err := os.WriteFile(mainFile, []byte(broken), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.NewFileEvent("main.go", ".go", mainFile, "write"); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("expected readiness error with stderr, got %v", err)
+	}
+	if _, body := getBody(t, url); body != "APP_OK" {
+		t.Fatalf("expected previous server to be restarted, got %q", body)
+	}
+	if !h.BuildStatus().Stale() {
+		t.Error("expected stale status after failed start")
+	}
+}
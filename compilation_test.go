@@ -230,7 +230,7 @@ func main() {
 	t.Logf("File event logs: %s", logOutput)
 }
 
-// TestNewFileEventOnOtherGoFiles verifica que cambios en otros archivos Go también recompilen
+// TestNewFileEventOnOtherGoFiles verifica que cambios en archivos Go que el servidor no importa no lo recompilen
 func TestNewFileEventOnOtherGoFiles(t *testing.T) {
 	tmp := t.TempDir()
 
@@ -321,17 +321,15 @@ func UtilFunction() string {
 	// Give it time to recompile and restart
 	time.Sleep(500 * time.Millisecond)
 
-	// Check logs to ensure recompilation happened
+	// utils.go is not imported by main.go, the event must be ignored
 	mu.Lock()
-	if len(logMessages) == 0 {
-		t.Error("Expected recompilation logs but got none")
-	}
-
-	// The log should contain messages about restarting
 	logOutput := strings.Join(logMessages, "\n")
 	mu.Unlock()
-	if !containsAny(logOutput, []string{"Go file modified", "restarting"}) {
-		t.Errorf("Expected restart messages in logs, got: %s", logOutput)
+	if containsAny(logOutput, []string{"Go file modified", "restarting"}) {
+		t.Errorf("Expected no restart for a file outside the server build, got: %s", logOutput)
+	}
+	if !containsAny(logOutput, []string{"not part of the server build"}) {
+		t.Errorf("Expected ignored event to be logged, got: %s", logOutput)
 	}
 
 	// Stop the server
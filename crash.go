@@ -0,0 +1,121 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	// crashStableAfter is how long a run must last for its exit to no longer
+	// count as consecutive with the previous crashes.
+	crashStableAfter = 10 * time.Second
+	// maxCrashRestartDelay caps the exponential backoff.
+	maxCrashRestartDelay = 30 * time.Second
+)
+
+// CrashReport describes an external server that exited without being stopped.
+type CrashReport struct {
+	Time     time.Time
+	ExitCode int      // -1 when killed by a signal or the exit status is unknown
+	Stderr   []string // last lines written to stderr before the exit
+}
+
+func (c CrashReport) String() string {
+	msg := fmt.Sprintf("exit code %d at %s", c.ExitCode, c.Time.Format(time.TimeOnly))
+	if len(c.Stderr) > 0 {
+		msg += "\nstderr:\n" + strings.Join(c.Stderr, "\n")
+	}
+	return msg
+}
+
+// exitCode extracts the exit status from a process error.
+func exitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// supervise watches the current run of p and restarts it if it exits on its own.
+func (s *externalStrategy) supervise(p *serverProcess) {
+	done := p.Done()
+	startedAt := time.Now()
+	go func() {
+		<-done
+		s.runMu.Lock()
+		defer s.runMu.Unlock()
+		if s.stopped || s.process != p || p.Running() || p.StopRequested() {
+			return // stopped or replaced on purpose
+		}
+
+		_, exitErr := p.Exited()
+		report := CrashReport{Time: time.Now(), ExitCode: exitCode(exitErr), Stderr: s.handler.output.stderrOf(p.Run())}
+		s.crashed(report, time.Since(startedAt) >= crashStableAfter)
+	}()
+}
+
+// crashed records report and schedules a restart with exponential backoff, or
+// reports a crash loop once Config.CrashRestartLimit is exceeded.
+// Must be called with s.runMu held.
+func (s *externalStrategy) crashed(report CrashReport, stable bool) {
+	s.mu.Lock()
+	if stable {
+		s.status.Crashes = 0
+	}
+	s.status.Crashes++
+	s.status.LastCrash = &report
+	crashes := s.status.Crashes
+	s.status.CrashLoop = crashes > s.handler.CrashRestartLimit
+	loop := s.status.CrashLoop
+	s.mu.Unlock()
+
+	if loop {
+		s.handler.logEvent(slog.LevelError, fmt.Sprintf("Server crash loop, giving up after %d restarts", crashes-1),
+			LogKeyBuild, s.buildID, LogKeyExitCode, report.ExitCode, LogKeyError, strings.Join(report.Stderr, "\n"))
+		s.showError("Server crashed", errors.New(report.String()))
+		return
+	}
+
+	delay := s.handler.CrashRestartDelay
+	for i := 1; i < crashes && delay < maxCrashRestartDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, maxCrashRestartDelay)
+	s.handler.logEvent(slog.LevelWarn, "Server exited unexpectedly, restarting in "+delay.String(),
+		LogKeyBuild, s.buildID, LogKeyExitCode, report.ExitCode, LogKeyError, strings.Join(report.Stderr, "\n"))
+
+	s.mu.Lock()
+	s.crashTimer = time.AfterFunc(delay, s.restartAfterCrash)
+	s.mu.Unlock()
+}
+
+// restartAfterCrash starts the last good binary again.
+func (s *externalStrategy) restartAfterCrash() {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	if s.stopped || s.process.Running() {
+		return
+	}
+
+	s.mu.Lock()
+	builtAt, took := s.status.BuiltAt, s.status.BuildTook
+	s.mu.Unlock()
+
+	// launch reports failures and schedules the next attempt itself
+	s.launch(builtAt, took)
+}
+
+// stopCrashRestarts cancels a pending restart. Must be called with s.runMu held.
+func (s *externalStrategy) stopCrashRestarts() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.crashTimer != nil {
+		s.crashTimer.Stop()
+		s.crashTimer = nil
+	}
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// crashingServer exits with code 3 on /crash and refuses to start without ./public,
+// like the generated template does when PublicDir is missing.
+const crashingServer = `package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+)
+
+func main() {
+	if _, err := os.Stat("public"); err != nil {
+		log.Fatal("public dir missing")
+	}
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "APP_OK")
+	})
+	http.HandleFunc("/crash", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(os.Stderr, "panic: boom")
+		os.Exit(3)
+	})
+	http.ListenAndServe("127.0.0.1:%s", nil)
+}
+`
+
+func newCrashingServer(t *testing.T, withPublic bool, limit int) (*ServerHandler, string) {
+	t.Helper()
+	tmp := t.TempDir()
+	sourceDir := filepath.Join(tmp, "src", "app")
+	if err := os.MkdirAll(sourceDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	port := freePort(t)
+	if err := os.WriteFile(filepath.Join(sourceDir, "main.go"), []byte(fmt.Sprintf(crashingServer, port)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if withPublic {
+		if err := os.MkdirAll(filepath.Join(tmp, "deploy", "public"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h := New(&Config{
+		AppRootDir:        tmp,
+		SourceDir:         "src/app",
+		OutputDir:         "deploy",
+		AppPort:           port,
+		ExitChan:          make(chan bool, 1),
+		CrashRestartDelay: 200 * time.Millisecond,
+		CrashRestartLimit: limit,
+	})
+	h.SetExternalServerMode(true)
+	t.Cleanup(func() { h.strategy.Stop() })
+	return h, "http://127.0.0.1:" + port
+}
+
+// waitStatus polls BuildStatus until cond holds.
+func waitStatus(t *testing.T, h *ServerHandler, cond func(BuildStatus) bool) BuildStatus {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		st := h.BuildStatus()
+		if cond(st) {
+			return st
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("status never reached, last: %s", st)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestCrashedServerIsRestarted(t *testing.T) {
+	h, url := newCrashingServer(t, true, 5)
+	if _, body := getBody(t, url); body != "APP_OK" {
+		t.Fatalf("expected app, got %q", body)
+	}
+
+	http.Get(url + "/crash")
+
+	st := waitStatus(t, h, func(st BuildStatus) bool { return st.Running && st.Crashes == 1 })
+	if st.LastCrash.ExitCode != 3 {
+		t.Errorf("expected exit code 3, got %d", st.LastCrash.ExitCode)
+	}
+	if !strings.Contains(strings.Join(st.LastCrash.Stderr, "\n"), "panic: boom") {
+		t.Errorf("expected stderr tail in crash report, got %v", st.LastCrash.Stderr)
+	}
+	if _, body := getBody(t, url); body != "APP_OK" {
+		t.Fatalf("expected restarted app, got %q", body)
+	}
+}
+
+func TestStartupCrashRecoversWithBackoff(t *testing.T) {
+	h, url := newCrashingServer(t, false, 5)
+	if h.BuildStatus().Running {
+		t.Fatal("server must not start without public dir")
+	}
+
+	// Fix the environment, the next backoff restart picks it up
+	if err := os.MkdirAll(filepath.Join(h.AppRootDir, "deploy", "public"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, h, func(st BuildStatus) bool { return st.Running })
+	if _, body := getBody(t, url); body != "APP_OK" {
+		t.Fatalf("expected app after recovery, got %q", body)
+	}
+}
+
+func TestCrashLoopStopsRestarting(t *testing.T) {
+	h, url := newCrashingServer(t, false, 2)
+
+	st := waitStatus(t, h, func(st BuildStatus) bool { return st.CrashLoop })
+	if st.Crashes != 3 || !strings.Contains(st.String(), "crash loop, gave up after 2 restarts") {
+		t.Errorf("unexpected crash loop status: %s", st)
+	}
+
+	status, body := getBody(t, url)
+	if status != http.StatusInternalServerError || !strings.Contains(body, "Server crashed") || !strings.Contains(body, "public dir missing") {
+		t.Errorf("expected crash page, got %d: %s", status, body)
+	}
+
+	time.Sleep(500 * time.Millisecond)
+	if h.BuildStatus().Crashes != 3 {
+		t.Error("expected no more restarts after the crash loop")
+	}
+}
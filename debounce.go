@@ -0,0 +1,61 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// rebuildDebouncer merges bursts of file events into a single rebuild.
+// An event arriving while a rebuild runs cancels it and schedules a fresh one.
+type rebuildDebouncer struct {
+	delay   time.Duration
+	rebuild func(paths []string) // runs the merged rebuild
+	cancel  func()               // aborts the rebuild in progress (e.g. the compile)
+
+	mu      sync.Mutex
+	timer   *time.Timer
+	pending []string // changed paths merged into the next rebuild
+	running int      // rebuilds in progress
+}
+
+// Trigger records an event for path and (re)starts the debounce window.
+func (d *rebuildDebouncer) Trigger(path string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.pending = append(d.pending, path)
+	if d.running > 0 {
+		// The build in progress is already stale
+		d.cancel()
+	}
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	d.timer = time.AfterFunc(d.delay, d.fire)
+}
+
+func (d *rebuildDebouncer) fire() {
+	d.mu.Lock()
+	paths := d.pending
+	d.pending = nil
+	d.timer = nil
+	d.running++
+	d.mu.Unlock()
+
+	d.rebuild(paths)
+
+	d.mu.Lock()
+	d.running--
+	d.mu.Unlock()
+}
+
+// Stop drops any pending rebuild.
+func (d *rebuildDebouncer) Stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	d.pending = nil
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDebouncerMergesBurst(t *testing.T) {
+	var mu sync.Mutex
+	var calls []int
+	d := &rebuildDebouncer{
+		delay: 50 * time.Millisecond,
+		rebuild: func(paths []string) {
+			mu.Lock()
+			calls = append(calls, len(paths))
+			mu.Unlock()
+		},
+		cancel: func() {},
+	}
+
+	for i := 0; i < 5; i++ {
+		d.Trigger("main.go")
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(150 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(calls) != 1 || calls[0] != 5 {
+		t.Fatalf("expected one rebuild merging 5 events, got %v", calls)
+	}
+}
+
+func TestDebouncerCancelsStaleRebuild(t *testing.T) {
+	started := make(chan struct{}, 2)
+	cancelled := make(chan struct{}, 2)
+	var mu sync.Mutex
+	rebuilds := 0
+
+	d := &rebuildDebouncer{delay: 20 * time.Millisecond}
+	d.rebuild = func(paths []string) {
+		mu.Lock()
+		rebuilds++
+		first := rebuilds == 1
+		mu.Unlock()
+		started <- struct{}{}
+		if first {
+			// simulate a long compile that only ends when cancelled
+			<-cancelled
+		}
+	}
+	d.cancel = func() { cancelled <- struct{}{} }
+
+	d.Trigger("main.go")
+	<-started
+
+	// A new event during the compile cancels it and schedules a fresh rebuild
+	d.Trigger("main.go")
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("expected a fresh rebuild after cancelling the stale one")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if rebuilds != 2 {
+		t.Fatalf("expected 2 rebuilds, got %d", rebuilds)
+	}
+}
+
+func TestDebouncerStopDropsPending(t *testing.T) {
+	called := make(chan int, 1)
+	d := &rebuildDebouncer{
+		delay:   30 * time.Millisecond,
+		rebuild: func(paths []string) { called <- len(paths) },
+		cancel:  func() {},
+	}
+	d.Trigger("main.go")
+	d.Stop()
+
+	select {
+	case <-called:
+		t.Fatal("rebuild ran after Stop")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType identifies a lifecycle Event.
+type EventType string
+
+// Lifecycle event types, see ServerHandler.Subscribe.
+const (
+	EventBuildStarted   EventType = "build_started"   // external binary compile started
+	EventBuildFailed    EventType = "build_failed"    // compile or start of a new build failed, see Err and Diagnostics
+	EventBuildSucceeded EventType = "build_succeeded" // external binary compiled
+	EventProcessStarted EventType = "process_started" // external binary started, see PID
+	EventProcessExited  EventType = "process_exited"  // external binary exited, see PID and ExitCode
+	EventReady          EventType = "ready"           // server accepts connections on Port
+	EventModeChanged    EventType = "mode_changed"    // switched to Mode
+	EventStopped        EventType = "stopped"         // server of Mode stopped
+)
+
+// Event is a server lifecycle change. Only the fields listed for its Type are set.
+type Event struct {
+	Type        EventType
+	Time        time.Time
+	Mode        string       // ModeExternal, ModeInMemory or a registered strategy name: ModeChanged, Stopped, Ready
+	BuildID     int          // BuildStarted, BuildSucceeded, ProcessStarted
+	PID         int          // ProcessStarted, ProcessExited, Ready in external mode
+	ExitCode    int          // ProcessExited, -1 when killed by a signal
+	Port        string       // Ready
+	Err         error        // BuildFailed
+	Diagnostics []Diagnostic // BuildFailed, compiler messages with source locations
+}
+
+// eventHub fans events out to subscribers without waiting for them.
+type eventHub struct {
+	mu   sync.Mutex
+	subs map[chan Event]struct{}
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{subs: make(map[chan Event]struct{})}
+}
+
+func (e *eventHub) emit(ev Event) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for c := range e.subs {
+		select {
+		case c <- ev:
+		default: // slow subscriber, drop rather than block the server
+		}
+	}
+}
+
+func (e *eventHub) subscribe() (<-chan Event, func()) {
+	c := make(chan Event, 64)
+	e.mu.Lock()
+	e.subs[c] = struct{}{}
+	e.mu.Unlock()
+
+	var once sync.Once
+	return c, func() {
+		once.Do(func() {
+			e.mu.Lock()
+			delete(e.subs, c)
+			e.mu.Unlock()
+			close(c)
+		})
+	}
+}
+
+// Subscribe delivers lifecycle events until cancel is called. Emitting never
+// blocks the server: events are dropped for a subscriber that does not keep up.
+func (h *ServerHandler) Subscribe() (events <-chan Event, cancel func()) {
+	return h.events.subscribe()
+}
+
+func (h *ServerHandler) emit(ev Event) {
+	ev.Time = time.Now()
+	h.events.emit(ev)
+}
+
+// modeChanged reports the mode the handler just switched to.
+func (h *ServerHandler) modeChanged(mode string) {
+	h.emit(Event{Type: EventModeChanged, Mode: mode})
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// nextEvent waits for an event of type want, skipping others.
+func nextEvent(t *testing.T, events <-chan Event, want EventType) Event {
+	t.Helper()
+	timeout := time.After(30 * time.Second)
+	for {
+		select {
+		case ev := <-events:
+			if ev.Type == want {
+				return ev
+			}
+		case <-timeout:
+			t.Fatalf("no %s event", want)
+		}
+	}
+}
+
+func TestSubscribeExternalLifecycle(t *testing.T) {
+	tmp := t.TempDir()
+	sourceDir := filepath.Join(tmp, "src", "app")
+	if err := os.MkdirAll(sourceDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(tmp, "deploy", "public"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	port := freePort(t)
+	mainFile := filepath.Join(sourceDir, "main.go")
+	if err := os.WriteFile(mainFile, []byte(fmt.Sprintf(crashingServer, port)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	h := New(&Config{
+		AppRootDir: tmp,
+		SourceDir:  "src/app",
+		OutputDir:  "deploy",
+		AppPort:    port,
+		ExitChan:   make(chan bool, 1),
+	})
+	events, cancel := h.Subscribe()
+	defer cancel()
+
+	h.SetExternalServerMode(true)
+	defer h.strategy.Stop()
+
+	if ev := nextEvent(t, events, EventModeChanged); ev.Mode != ModeExternal {
+		t.Fatalf("expected mode %s, got %s", ModeExternal, ev.Mode)
+	}
+	nextEvent(t, events, EventBuildStarted)
+	if ev := nextEvent(t, events, EventBuildSucceeded); ev.BuildID != 1 {
+		t.Fatalf("expected build 1, got %d", ev.BuildID)
+	}
+	started := nextEvent(t, events, EventProcessStarted)
+	if started.PID == 0 {
+		t.Fatal("ProcessStarted without PID")
+	}
+	if ev := nextEvent(t, events, EventReady); ev.Port != port || ev.PID != started.PID {
+		t.Fatalf("unexpected ready event %+v", ev)
+	}
+
+	if err := os.WriteFile(mainFile, []byte("package main\n\nfunc main() {\n\tundefinedCall()\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	h.RestartServer()
+	failed := nextEvent(t, events, EventBuildFailed)
+	if failed.Err == nil || len(failed.Diagnostics) == 0 || failed.Diagnostics[0].Line != 4 {
+		t.Fatalf("expected diagnostics at line 4, got %+v", failed)
+	}
+
+	h.strategy.Stop()
+	if ev := nextEvent(t, events, EventProcessExited); ev.PID != started.PID {
+		t.Fatalf("expected exit of %d, got %d", started.PID, ev.PID)
+	}
+	if ev := nextEvent(t, events, EventStopped); ev.Mode != ModeExternal {
+		t.Fatalf("unexpected stopped event %+v", ev)
+	}
+}
+
+func TestSubscribeNeverBlocks(t *testing.T) {
+	h := New(nil)
+	events, cancel := h.Subscribe()
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 1000; i++ {
+			h.emit(Event{Type: EventReady})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("emit blocked on a subscriber that does not read")
+	}
+
+	cancel()
+	count := 0
+	for range events {
+		count++
+	}
+	if count == 0 || count == 1000 {
+		t.Fatalf("expected the buffered events only, got %d", count)
+	}
+}
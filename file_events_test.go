@@ -0,0 +1,177 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// mainWithHandlers serves APP_OK and imports the local handlers package.
+const mainWithHandlers = `package main
+
+import (
+	"fmt"
+	"net/http"
+
+	_ "testapp/src/app/handlers"
+)
+
+func main() {
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "APP_OK")
+	})
+	http.ListenAndServe("127.0.0.1:%s", nil)
+}
+`
+
+const extraHandlerFile = `package handlers
+
+import (
+	"fmt"
+	"net/http"
+)
+
+func init() {
+	http.HandleFunc("/extra", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "EXTRA_OK")
+	})
+}
+`
+
+func TestExternalHandlesCreateRemoveRename(t *testing.T) {
+	tmp := t.TempDir()
+	sourceDir := filepath.Join(tmp, "src", "app")
+	handlersDir := filepath.Join(sourceDir, "handlers")
+	if err := os.MkdirAll(handlersDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	port := freePort(t)
+	mainFile := filepath.Join(sourceDir, "main.go")
+	mainContent := fmt.Sprintf(mainWithHandlers, port)
+	files := map[string]string{
+		filepath.Join(tmp, "go.mod"):              "module testapp\n\ngo 1.21\n",
+		filepath.Join(handlersDir, "handlers.go"): "package handlers\n",
+		mainFile: mainContent,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h := New(&Config{
+		AppRootDir: tmp,
+		SourceDir:  "src/app",
+		OutputDir:  "deploy",
+		AppPort:    port,
+		Routes:     []func(*http.ServeMux){textRoute("/", "MEMORY_OK")},
+		ExitChan:   make(chan bool, 1),
+	})
+	h.SetExternalServerMode(true)
+	defer func() { h.strategy.Stop() }()
+
+	url := "http://127.0.0.1:" + port
+
+	// create: new handler file is compiled in
+	extraFile := filepath.Join(handlersDir, "extra.go")
+	if err := os.WriteFile(extraFile, []byte(extraHandlerFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.NewFileEvent("extra.go", ".go", extraFile, "create"); err != nil {
+		t.Fatalf("create event: %v", err)
+	}
+	if _, body := getBody(t, url+"/extra"); body != "EXTRA_OK" {
+		t.Fatalf("expected created handler to be served, got %q", body)
+	}
+
+	// rename: the old path is reported, the file is gone from the package
+	renamed := filepath.Join(handlersDir, "extra.go.bak")
+	if err := os.Rename(extraFile, renamed); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.NewFileEvent("extra.go", ".go", extraFile, "rename"); err != nil {
+		t.Fatalf("rename event: %v", err)
+	}
+	if _, body := getBody(t, url+"/extra"); body != "APP_OK" {
+		t.Fatalf("expected renamed handler to be dropped, got %q", body)
+	}
+
+	// remove of the main input file: fall back to in-memory
+	if err := os.Remove(mainFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.NewFileEvent("main.go", ".go", mainFile, "remove"); err != nil {
+		t.Fatalf("remove event: %v", err)
+	}
+	if h.currentMode() != ModeInMemory || h.strategy.Name() != "In-Memory" {
+		t.Fatalf("expected in-memory fallback, strategy is %s", h.strategy.Name())
+	}
+	if _, body := getBody(t, url); body != "MEMORY_OK" {
+		t.Fatalf("expected in-memory routes after fallback, got %q", body)
+	}
+
+	// main input file is back: external mode again
+	if err := os.WriteFile(mainFile, []byte(mainContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.NewFileEvent("main.go", ".go", mainFile, "create"); err != nil {
+		t.Fatalf("create event: %v", err)
+	}
+	if h.currentMode() != ModeExternal {
+		t.Fatal("expected external mode to be restored")
+	}
+	if _, body := getBody(t, url); body != "APP_OK" {
+		t.Fatalf("expected external server after restore, got %q", body)
+	}
+}
+
+func TestDebouncedFallbackToInMemory(t *testing.T) {
+	tmp := t.TempDir()
+	sourceDir := filepath.Join(tmp, "src", "app")
+	if err := os.MkdirAll(sourceDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	port := freePort(t)
+	mainFile := filepath.Join(sourceDir, "main.go")
+	main := fmt.Sprintf("package main\n\nimport \"net/http\"\n\nfunc main() { http.ListenAndServe(\"127.0.0.1:%s\", nil) }\n", port)
+	if err := os.WriteFile(mainFile, []byte(main), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	exit := make(chan bool, 1)
+	h := New(&Config{
+		AppRootDir:    tmp,
+		SourceDir:     "src/app",
+		OutputDir:     "deploy",
+		AppPort:       port,
+		Routes:        []func(*http.ServeMux){textRoute("/", "MEMORY_OK")},
+		DebounceDelay: 50 * time.Millisecond,
+		ExitChan:      exit,
+	})
+	h.SetExternalServerMode(true)
+	defer func() { exit <- true }()
+
+	if err := os.Remove(mainFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.NewFileEvent("main.go", ".go", mainFile, "remove"); err != nil {
+		t.Fatalf("remove event: %v", err)
+	}
+
+	// The switch happens on the debouncer goroutine while Status is polled
+	deadline := time.Now().Add(5 * time.Second)
+	for h.Status().Mode != ModeInMemory {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected in-memory fallback, mode is %s", h.Status().Mode)
+		}
+		h.UnobservedFiles()
+		h.BuildStatus()
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, body := getBody(t, "http://127.0.0.1:"+port); body != "MEMORY_OK" {
+		t.Fatalf("expected in-memory routes after fallback, got %q", body)
+	}
+}
@@ -15,8 +15,11 @@ import (
 var embeddedFS embed.FS
 
 type serverTemplateData struct {
-	AppPort   string
-	PublicDir string
+	AppPort              string
+	PublicDir            string
+	CrossOriginIsolation bool
+	SPAFallback          bool
+	SPAExcludePrefixes   []string
 }
 
 // generateServerFromEmbeddedMarkdown creates the external server go file from the embedded markdown
@@ -32,8 +35,11 @@ func (h *ServerHandler) generateServerFromEmbeddedMarkdown() error {
 	}
 
 	data := serverTemplateData{
-		AppPort:   h.AppPort,
-		PublicDir: h.PublicDir,
+		AppPort:              h.AppPort,
+		PublicDir:            h.PublicDir,
+		CrossOriginIsolation: h.CrossOriginIsolation,
+		SPAFallback:          h.SPAFallback,
+		SPAExcludePrefixes:   h.SPAExcludePrefixes,
 	}
 
 	// read embedded markdown
@@ -3,6 +3,7 @@ package server
 import (
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -106,3 +107,66 @@ func TestGenerateDoesNotOverwrite(t *testing.T) {
 		t.Fatalf("file was overwritten, expected original content")
 	}
 }
+
+func TestGeneratedServerCrossOriginIsolationOption(t *testing.T) {
+	for _, isolated := range []bool{false, true} {
+		tmp := t.TempDir()
+		h := newTestHandler(t, "src/app", "deploy", tmp)
+		h.CrossOriginIsolation = isolated
+		if err := h.generateServerFromEmbeddedMarkdown(); err != nil {
+			t.Fatalf("generate failed: %v", err)
+		}
+		b, err := os.ReadFile(filepath.Join(tmp, "src/app", h.mainFileExternalServer))
+		if err != nil {
+			t.Fatalf("reading generated file: %v", err)
+		}
+		content := string(b)
+
+		if !strings.Contains(content, "precompressed(absPublicDir") {
+			t.Error("generated file does not serve precompressed files")
+		}
+		if got := strings.Contains(content, "Cross-Origin-Embedder-Policy"); got != isolated {
+			t.Errorf("CrossOriginIsolation %v: isolation headers in generated file: %v", isolated, got)
+		}
+
+		// The generated server must build in both variants
+		if err := os.WriteFile(filepath.Join(tmp, "src/app", "go.mod"), []byte("module gen\n\ngo 1.22\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cmd := exec.Command("go", "vet", ".")
+		cmd.Dir = filepath.Join(tmp, "src/app")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("CrossOriginIsolation %v: generated server does not build: %v\n%s", isolated, err, out)
+		}
+	}
+}
+
+func TestGeneratedServerSPAFallbackOption(t *testing.T) {
+	for _, spa := range []bool{false, true} {
+		tmp := t.TempDir()
+		h := newTestHandler(t, "src/app", "deploy", tmp)
+		h.SPAFallback = spa
+		h.SPAExcludePrefixes = []string{"/api/", "/ws/"}
+		if err := h.generateServerFromEmbeddedMarkdown(); err != nil {
+			t.Fatalf("generate failed: %v", err)
+		}
+		b, err := os.ReadFile(filepath.Join(tmp, "src/app", h.mainFileExternalServer))
+		if err != nil {
+			t.Fatalf("reading generated file: %v", err)
+		}
+		content := string(b)
+
+		if got := strings.Contains(content, `spaExclude := []string{"/api/", "/ws/"}`); got != spa {
+			t.Errorf("SPAFallback %v: SPA fallback in generated file: %v", spa, got)
+		}
+
+		if err := os.WriteFile(filepath.Join(tmp, "src/app", "go.mod"), []byte("module gen\n\ngo 1.22\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cmd := exec.Command("go", "vet", ".")
+		cmd.Dir = filepath.Join(tmp, "src/app")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("SPAFallback %v: generated server does not build: %v\n%s", spa, err, out)
+		}
+	}
+}
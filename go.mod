@@ -5,7 +5,6 @@ go 1.25.2
 require (
 	github.com/tinywasm/devflow v0.0.26
 	github.com/tinywasm/gobuild v0.0.21
-	github.com/tinywasm/gorun v0.0.15
 )
 
 require (
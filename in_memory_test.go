@@ -0,0 +1,343 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func freePort(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	return port
+}
+
+// getBody polls url until it answers or the timeout expires.
+func getBody(t *testing.T, url string) (int, string) {
+	t.Helper()
+	client := &http.Client{Timeout: time.Second}
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		resp, err := client.Get(url)
+		if err == nil {
+			b, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			return resp.StatusCode, string(b)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("GET %s: %v", url, err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func textRoute(pattern, body string) func(*http.ServeMux) {
+	return func(mux *http.ServeMux) {
+		mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, body)
+		})
+	}
+}
+
+func TestInMemoryRestartReRegistersRoutes(t *testing.T) {
+	port := freePort(t)
+	cfg := &Config{
+		AppRootDir: t.TempDir(),
+		AppPort:    port,
+		Routes:     []func(*http.ServeMux){textRoute("/v", "v1")},
+		ExitChan:   make(chan bool, 1),
+	}
+	h := New(cfg)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go h.StartServer(&wg)
+
+	if _, body := getBody(t, "http://127.0.0.1:"+port+"/v"); body != "v1" {
+		t.Fatalf("expected v1, got %q", body)
+	}
+
+	// Change routes and port, then restart
+	newPort := freePort(t)
+	cfg.Routes = []func(*http.ServeMux){textRoute("/v", "v2")}
+	cfg.AppPort = newPort
+	if err := h.RestartServer(); err != nil {
+		t.Fatalf("RestartServer: %v", err)
+	}
+
+	if _, body := getBody(t, "http://127.0.0.1:"+newPort+"/v"); body != "v2" {
+		t.Fatalf("expected v2 after restart, got %q", body)
+	}
+
+	// The goroutine blocked in Start must stop the restarted server
+	cfg.ExitChan <- true
+	wg.Wait()
+
+	client := &http.Client{Timeout: time.Second}
+	if resp, err := client.Get("http://127.0.0.1:" + newPort + "/v"); err == nil {
+		resp.Body.Close()
+		t.Fatal("expected restarted server to be stopped after ExitChan")
+	}
+}
+
+func TestReplaceRoutesKeepsConnections(t *testing.T) {
+	port := freePort(t)
+	release := make(chan struct{})
+	slow := func(mux *http.ServeMux) {
+		mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+			<-release
+			fmt.Fprint(w, "slow-done")
+		})
+	}
+	cfg := &Config{
+		AppRootDir: t.TempDir(),
+		AppPort:    port,
+		Routes:     []func(*http.ServeMux){textRoute("/v", "v1"), slow},
+		ExitChan:   make(chan bool, 1),
+	}
+	h := New(cfg)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go h.StartServer(&wg)
+	defer func() {
+		cfg.ExitChan <- true
+		wg.Wait()
+	}()
+
+	base := "http://127.0.0.1:" + port
+	if _, body := getBody(t, base+"/v"); body != "v1" {
+		t.Fatalf("expected v1, got %q", body)
+	}
+
+	// Start an in-flight request that outlives the swap
+	inFlight := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(base + "/slow")
+		if err != nil {
+			inFlight <- err.Error()
+			return
+		}
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		inFlight <- string(b)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	var mu sync.Mutex
+	var localAddrs []string
+	transport := &http.Transport{DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := (&net.Dialer{}).DialContext(ctx, network, addr)
+		if err == nil {
+			mu.Lock()
+			localAddrs = append(localAddrs, conn.LocalAddr().String())
+			mu.Unlock()
+		}
+		return conn, err
+	}}
+	client := &http.Client{Transport: transport}
+	get := func(path string) string {
+		resp, err := client.Get(base + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return string(b)
+	}
+
+	if got := get("/v"); got != "v1" {
+		t.Fatalf("expected v1, got %q", got)
+	}
+
+	if err := h.ReplaceRoutes([]func(*http.ServeMux){textRoute("/v", "v2"), slow}); err != nil {
+		t.Fatalf("ReplaceRoutes: %v", err)
+	}
+
+	if got := get("/v"); got != "v2" {
+		t.Fatalf("expected v2 after swap, got %q", got)
+	}
+	mu.Lock()
+	dials := len(localAddrs)
+	mu.Unlock()
+	if dials != 1 {
+		t.Errorf("expected keep-alive connection to be reused, dialed %d times", dials)
+	}
+
+	close(release)
+	if body := <-inFlight; body != "slow-done" {
+		t.Errorf("in-flight request interrupted, got %q", body)
+	}
+}
+
+func TestReplaceRoutesKeepsPreviousOnPanic(t *testing.T) {
+	port := freePort(t)
+	cfg := &Config{
+		AppRootDir: t.TempDir(),
+		AppPort:    port,
+		Routes:     []func(*http.ServeMux){textRoute("/v", "v1")},
+		ExitChan:   make(chan bool, 1),
+	}
+	h := New(cfg)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go h.StartServer(&wg)
+	defer func() {
+		cfg.ExitChan <- true
+		wg.Wait()
+	}()
+	getBody(t, "http://127.0.0.1:"+port+"/v")
+
+	duplicated := []func(*http.ServeMux){textRoute("/v", "a"), textRoute("/v", "b")}
+	if err := h.ReplaceRoutes(duplicated); err == nil {
+		t.Fatal("expected error for duplicated pattern")
+	}
+	if _, body := getBody(t, "http://127.0.0.1:"+port+"/v"); body != "v1" {
+		t.Errorf("expected previous routes to keep serving, got %q", body)
+	}
+	if len(cfg.Routes) != 1 {
+		t.Errorf("expected Config.Routes to be restored")
+	}
+}
+
+func TestInMemoryRestartClosesLongRequests(t *testing.T) {
+	port := freePort(t)
+	release := make(chan struct{})
+	defer close(release)
+	slow := func(mux *http.ServeMux) {
+		mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		})
+	}
+	cfg := &Config{
+		AppRootDir:      t.TempDir(),
+		AppPort:         port,
+		Routes:          []func(*http.ServeMux){slow},
+		ExitChan:        make(chan bool, 1),
+		StopGracePeriod: 200 * time.Millisecond,
+	}
+	h := New(cfg)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go h.StartServer(&wg)
+	defer func() {
+		cfg.ExitChan <- true
+		wg.Wait()
+	}()
+
+	base := "http://127.0.0.1:" + port
+	getBody(t, base+"/health")
+	go func() {
+		if resp, err := http.Get(base + "/slow"); err == nil {
+			resp.Body.Close()
+		}
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	// Status must not wait for the drain
+	statusDone := make(chan struct{})
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		h.Status()
+		close(statusDone)
+	}()
+	if err := h.RestartServer(); err != nil {
+		t.Fatalf("RestartServer: %v", err)
+	}
+	select {
+	case <-statusDone:
+	default:
+		t.Error("Status blocked while connections drained")
+	}
+
+	if code, _ := getBody(t, base+"/health"); code != http.StatusOK {
+		t.Fatalf("expected /health after restart, got %d", code)
+	}
+	if err := h.RestartServer(); err != nil {
+		t.Fatalf("second RestartServer: %v", err)
+	}
+	if code, _ := getBody(t, base+"/health"); code != http.StatusOK {
+		t.Fatalf("expected /health after second restart, got %d", code)
+	}
+}
+
+func TestInMemoryRestartInQuickSuccession(t *testing.T) {
+	port := freePort(t)
+	cfg := &Config{AppRootDir: t.TempDir(), AppPort: port, ExitChan: make(chan bool, 1)}
+	h := New(cfg)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go h.StartServer(&wg)
+	defer func() {
+		cfg.ExitChan <- true
+		wg.Wait()
+	}()
+	getBody(t, "http://127.0.0.1:"+port+"/health")
+
+	// Each server is shut down before its Serve goroutine may have run
+	for i := 0; i < 20; i++ {
+		if err := h.RestartServer(); err != nil {
+			t.Fatalf("RestartServer %d: %v", i, err)
+		}
+	}
+	if code, _ := getBody(t, "http://127.0.0.1:"+port+"/health"); code != http.StatusOK {
+		t.Fatalf("expected /health after restarts, got %d", code)
+	}
+}
+
+func TestReplaceRoutesDuringRestart(t *testing.T) {
+	port := freePort(t)
+	cfg := &Config{
+		AppRootDir: t.TempDir(),
+		AppPort:    port,
+		Routes:     []func(*http.ServeMux){textRoute("/v", "v0")},
+		ExitChan:   make(chan bool, 1),
+	}
+	h := New(cfg)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go h.StartServer(&wg)
+	defer func() {
+		cfg.ExitChan <- true
+		wg.Wait()
+	}()
+	getBody(t, "http://127.0.0.1:"+port+"/v")
+
+	// Run with -race: Restart reads Config.Routes while ReplaceRoutes sets it
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 5; i++ {
+			if err := h.RestartServer(); err != nil {
+				t.Errorf("RestartServer: %v", err)
+			}
+		}
+	}()
+	for i := 1; i <= 20; i++ {
+		if err := h.ReplaceRoutes([]func(*http.ServeMux){textRoute("/v", fmt.Sprint("v", i))}); err != nil {
+			t.Fatalf("ReplaceRoutes: %v", err)
+		}
+	}
+	<-done
+
+	if _, body := getBody(t, "http://127.0.0.1:"+port+"/v"); body != "v20" {
+		t.Errorf("expected the last routes to be served, got %q", body)
+	}
+}
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// buildInputs describes what the external binary is built from, as reported by
+// go list: the local packages it imports, the files they embed, go.mod/go.sum
+// and the ArgumentsForCompilingServer flags.
+type buildInputs struct {
+	hash   string
+	files  map[string]bool // absolute paths included in hash
+	dirs   map[string]bool // directories of the imported local packages
+	embeds []string        // absolute //go:embed patterns of those packages
+	exts   []string        // extensions of the embedded files e.g. ".html", see SupportedExtensions
+}
+
+// Contains reports whether path is one of the hashed files.
+func (b buildInputs) Contains(path string) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	return b.files[abs]
+}
+
+// Affects reports whether a change to path can change the binary: a hashed
+// file, a Go file added to or removed from an imported package, a file
+// matching an embed pattern or a module file above the packages.
+func (b buildInputs) Affects(path string) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return true
+	}
+	if b.files[abs] {
+		return true
+	}
+
+	dir, name := filepath.Split(abs)
+	dir = filepath.Clean(dir)
+	if filepath.Ext(name) == ".go" && !strings.HasSuffix(name, "_test.go") && b.dirs[dir] {
+		return true
+	}
+
+	for _, pattern := range b.embeds {
+		if ok, _ := filepath.Match(pattern, abs); ok || strings.HasPrefix(abs, pattern+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	switch name {
+	case "go.mod", "go.sum", "go.work":
+		for d := range b.dirs {
+			if d == dir || strings.HasPrefix(d, dir+string(filepath.Separator)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// listedPackage holds the go list -json fields used by readBuildInputs.
+type listedPackage struct {
+	Dir           string
+	ImportPath    string
+	Standard      bool
+	GoFiles       []string
+	CgoFiles      []string
+	EmbedPatterns []string
+	EmbedFiles    []string
+	Module        *struct {
+		GoMod   string
+		Main    bool
+		Replace *struct{ Version string }
+	}
+}
+
+// local reports whether the package source lives in the project rather than
+// in GOROOT or the module cache.
+func (p listedPackage) local() bool {
+	if p.Standard {
+		return false
+	}
+	if p.Module == nil || p.Module.Main {
+		return true
+	}
+	// replace directive pointing at a directory
+	return p.Module.Replace != nil && p.Module.Replace.Version == ""
+}
+
+// buildTags returns the value of the last -tags flag in args, the build
+// arguments gobuild passes on to go build.
+func buildTags(args []string) string {
+	tags := ""
+	for i := 0; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(args[i], "-"), "=")
+		if name != "-tags" && name != "tags" {
+			continue
+		}
+		if !hasValue && i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+		tags = value
+	}
+	return tags
+}
+
+// listDeps runs go list -deps on the main input file, with the build tags of
+// ArgumentsForCompilingServer so it sees the files go build compiles.
+func (h *ServerHandler) listDeps(mainFile string) ([]listedPackage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	args := []string{"list", "-e", "-deps", "-json"}
+	if h.ArgumentsForCompilingServer != nil {
+		if tags := buildTags(h.ArgumentsForCompilingServer()); tags != "" {
+			args = append(args, "-tags="+tags)
+		}
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "go", append(args, mainFile)...)
+	cmd.Dir = filepath.Dir(mainFile)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Join(errors.New("go list: "+strings.TrimSpace(stderr.String())), err)
+	}
+
+	var pkgs []listedPackage
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for {
+		var p listedPackage
+		if err := dec.Decode(&p); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		pkgs = append(pkgs, p)
+	}
+	return pkgs, nil
+}
+
+func (h *ServerHandler) readBuildInputs() (buildInputs, error) {
+	mainFile, err := filepath.Abs(filepath.Join(h.AppRootDir, h.MainInputFileRelativePath()))
+	if err != nil {
+		return buildInputs{}, err
+	}
+
+	pkgs, err := h.listDeps(mainFile)
+	if err != nil {
+		return buildInputs{}, err
+	}
+
+	in := buildInputs{files: make(map[string]bool), dirs: make(map[string]bool)}
+	in.files[mainFile] = true
+	for _, p := range pkgs {
+		if !p.local() {
+			continue
+		}
+		// The main file is built on its own, other files in its directory are not compiled
+		if p.ImportPath != "command-line-arguments" {
+			in.dirs[p.Dir] = true
+		}
+		for _, f := range append(append(p.GoFiles, p.CgoFiles...), p.EmbedFiles...) {
+			in.files[filepath.Join(p.Dir, f)] = true
+		}
+		for _, f := range p.EmbedFiles {
+			if ext := filepath.Ext(f); ext != "" && !slices.Contains(in.exts, ext) {
+				in.exts = append(in.exts, ext)
+			}
+		}
+		for _, pattern := range p.EmbedPatterns {
+			in.embeds = append(in.embeds, filepath.Join(p.Dir, strings.TrimPrefix(pattern, "all:")))
+		}
+		if p.Module != nil && p.Module.GoMod != "" {
+			in.files[p.Module.GoMod] = true
+			if sum := filepath.Join(filepath.Dir(p.Module.GoMod), "go.sum"); fileExists(sum) {
+				in.files[sum] = true
+			}
+		}
+	}
+
+	sorted := make([]string, 0, len(in.files))
+	for f := range in.files {
+		sorted = append(sorted, f)
+	}
+	sort.Strings(sorted)
+
+	sum := sha256.New()
+	for _, f := range sorted {
+		content, err := os.ReadFile(f)
+		if err != nil {
+			return buildInputs{}, err
+		}
+		sum.Write([]byte(f))
+		sum.Write([]byte{0})
+		sum.Write(content)
+		sum.Write([]byte{0})
+	}
+	if h.ArgumentsForCompilingServer != nil {
+		sum.Write([]byte(strings.Join(h.ArgumentsForCompilingServer(), "\x00")))
+	}
+
+	in.hash = hex.EncodeToString(sum.Sum(nil))
+	return in, nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
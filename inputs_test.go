@@ -0,0 +1,287 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// embedServer embeds assets and imports the local handlers package.
+const embedServer = `package main
+
+import (
+	"embed"
+
+	_ "testapp/src/app/handlers"
+)
+
+//go:embed assets
+var assets embed.FS
+
+func main() {}
+`
+
+func TestReadBuildInputs(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "src", "app")
+	for _, dir := range []string{"src/app/assets", "src/app/handlers", "src/web"} {
+		if err := os.MkdirAll(filepath.Join(tmp, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(tmp, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("go.mod", "module testapp\n\ngo 1.21\n")
+	write("src/app/main.go", embedServer)
+	write("src/app/main_test.go", "package main\n")
+	write("src/app/assets/index.html", "v1")
+	write("src/app/handlers/handlers.go", "package handlers\n")
+	write("src/web/main.go", "package main\n\nfunc main() {}\n")
+
+	flags := []string{"-X main.version=1"}
+	h := New(&Config{
+		AppRootDir:                  tmp,
+		SourceDir:                   "src/app",
+		ArgumentsForCompilingServer: func() []string { return flags },
+	})
+
+	first, err := h.readBuildInputs()
+	if err != nil {
+		t.Fatalf("readBuildInputs: %v", err)
+	}
+	for _, f := range []string{"go.mod", "src/app/assets/index.html", "src/app/handlers/handlers.go"} {
+		if !first.Contains(filepath.Join(tmp, f)) {
+			t.Errorf("expected %s to be tracked", f)
+		}
+	}
+	if first.Contains(filepath.Join(src, "main_test.go")) {
+		t.Error("test files must not be tracked")
+	}
+
+	affects := map[string]bool{
+		"src/app/main.go":              true,
+		"src/app/handlers/new.go":      true, // file added to an imported package
+		"src/app/handlers/new_test.go": false,
+		"src/app/assets/new.css":       true, // new file under an embedded directory
+		"go.sum":                       true,
+		"src/app/utils.go":             false, // next to main.go but not compiled with it
+		"src/web/main.go":              false, // frontend, not imported
+	}
+	for f, want := range affects {
+		if got := first.Affects(filepath.Join(tmp, f)); got != want {
+			t.Errorf("Affects(%s) = %v, want %v", f, got, want)
+		}
+	}
+
+	// Byte-identical save keeps the hash
+	write("src/app/main.go", embedServer)
+	if again, _ := h.readBuildInputs(); again.hash != first.hash {
+		t.Error("expected identical content to keep the hash")
+	}
+
+	write("src/app/assets/index.html", "v2")
+	embedChanged, _ := h.readBuildInputs()
+	if embedChanged.hash == first.hash {
+		t.Error("expected embedded file change to change the hash")
+	}
+
+	flags = []string{"-X main.version=2"}
+	if flagsChanged, _ := h.readBuildInputs(); flagsChanged.hash == embedChanged.hash {
+		t.Error("expected build flags to change the hash")
+	}
+}
+
+func TestUnchangedInputsSkipRecompile(t *testing.T) {
+	tmp := t.TempDir()
+	sourceDir := filepath.Join(tmp, "src", "app")
+	if err := os.MkdirAll(sourceDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	port := freePort(t)
+	mainFile := filepath.Join(sourceDir, "main.go")
+	if err := os.WriteFile(mainFile, []byte(fmt.Sprintf(fixedPortServer, port)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var logs []string
+	h := New(&Config{
+		AppRootDir: tmp,
+		SourceDir:  "src/app",
+		OutputDir:  "deploy",
+		AppPort:    port,
+		ExitChan:   make(chan bool, 1),
+	})
+	h.SetLog(func(messages ...any) {
+		mu.Lock()
+		logs = append(logs, fmt.Sprint(messages...))
+		mu.Unlock()
+	})
+	h.SetExternalServerMode(true)
+	defer h.strategy.Stop()
+
+	logged := func(want string) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return strings.Contains(strings.Join(logs, "\n"), want)
+	}
+
+	// Save without changes
+	if err := h.NewFileEvent("main.go", ".go", mainFile, "write"); err != nil {
+		t.Fatalf("NewFileEvent: %v", err)
+	}
+	if !logged("Rebuild skipped: server inputs unchanged") {
+		t.Errorf("expected skipped rebuild to be logged, got: %v", logs)
+	}
+
+	// Process died: restart it without recompiling
+	ext := h.strategy.(*externalStrategy)
+	binary := filepath.Join(tmp, "deploy", ext.goCompiler.MainOutputFileNameWithExtension())
+	before, err := os.Stat(binary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ext.process.Stop(time.Second)
+
+	if err := h.NewFileEvent("main.go", ".go", mainFile, "write"); err != nil {
+		t.Fatalf("NewFileEvent: %v", err)
+	}
+	if !logged("Recompile skipped: server inputs unchanged, restarting process") {
+		t.Errorf("expected process-only restart to be logged, got: %v", logs)
+	}
+	if _, body := getBody(t, "http://127.0.0.1:"+port); body != "APP_OK" {
+		t.Errorf("expected restarted server, got %q", body)
+	}
+	after, _ := os.Stat(binary)
+	if !after.ModTime().Equal(before.ModTime()) {
+		t.Error("binary was rebuilt although inputs did not change")
+	}
+}
+
+// embeddedPageServer serves the embedded page.html on the given port.
+const embeddedPageServer = `package main
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+//go:embed page.html
+var page string
+
+//go:embed note.tmpl
+var note string
+
+func main() {
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(page)) })
+	http.ListenAndServe("127.0.0.1:%s", nil)
+}
+`
+
+func TestEmbeddedAssetEditRebuilds(t *testing.T) {
+	tmp := t.TempDir()
+	sourceDir := filepath.Join(tmp, "src", "app")
+	if err := os.MkdirAll(sourceDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	port := freePort(t)
+	page := filepath.Join(sourceDir, "page.html")
+	files := map[string]string{
+		filepath.Join(tmp, "go.mod"):           "module testapp\n\ngo 1.21\n",
+		filepath.Join(sourceDir, "main.go"):    fmt.Sprintf(embeddedPageServer, port),
+		filepath.Join(sourceDir, "note.tmpl"):  "note",
+		page:                                   "PAGE_V1",
+		filepath.Join(sourceDir, "other.html"): "not embedded",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h := New(&Config{
+		AppRootDir: tmp,
+		SourceDir:  "src/app",
+		OutputDir:  "deploy",
+		AppPort:    port,
+		LiveReload: true,
+		ExitChan:   make(chan bool, 1),
+	})
+	// The watcher query does not run go list before the first build
+	if exts := newExternalStrategy(h).embedExtensions(); len(exts) != 0 {
+		t.Errorf("expected no embedded extensions before the first build, got %v", exts)
+	}
+
+	h.SetExternalServerMode(true)
+	defer h.strategy.Stop()
+
+	if exts := strings.Join(h.SupportedExtensions(), " "); !strings.Contains(exts, ".tmpl") {
+		t.Errorf("expected embedded .tmpl files to be watched, got %s", exts)
+	}
+	if _, body := getBody(t, "http://127.0.0.1:"+port); body != "PAGE_V1" {
+		t.Fatalf("expected PAGE_V1, got %q", body)
+	}
+	builds := h.strategy.(*externalStrategy).builds
+
+	// An asset outside the build only reloads the browsers
+	if err := h.NewFileEvent("other.html", ".html", filepath.Join(sourceDir, "other.html"), "write"); err != nil {
+		t.Fatalf("NewFileEvent: %v", err)
+	}
+	if got := h.strategy.(*externalStrategy).builds; got != builds {
+		t.Fatalf("expected no rebuild for a file outside the build, builds %d -> %d", builds, got)
+	}
+
+	// An embedded asset is compiled into the binary
+	if err := os.WriteFile(page, []byte("PAGE_V2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.NewFileEvent("page.html", ".html", page, "write"); err != nil {
+		t.Fatalf("NewFileEvent: %v", err)
+	}
+	if _, body := getBody(t, "http://127.0.0.1:"+port); body != "PAGE_V2" {
+		t.Fatalf("expected rebuilt binary to serve PAGE_V2, got %q", body)
+	}
+}
+
+func TestReadBuildInputsUsesBuildTags(t *testing.T) {
+	tmp := t.TempDir()
+	handlers := filepath.Join(tmp, "src", "app", "handlers")
+	if err := os.MkdirAll(handlers, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		filepath.Join(tmp, "go.mod"):           "module testapp\n\ngo 1.21\n",
+		filepath.Join(tmp, "src/app/main.go"):  "package main\n\nimport _ \"testapp/src/app/handlers\"\n\nfunc main() {}\n",
+		filepath.Join(handlers, "handlers.go"): "package handlers\n",
+		filepath.Join(handlers, "dev.go"):      "//go:build dev\n\npackage handlers\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	devFile := filepath.Join(handlers, "dev.go")
+
+	for _, args := range [][]string{nil, {"-tags=dev"}, {"-tags", "dev", "-X main.v=1"}, {"--tags=dev"}} {
+		h := New(&Config{
+			AppRootDir:                  tmp,
+			SourceDir:                   "src/app",
+			ArgumentsForCompilingServer: func() []string { return args },
+		})
+		in, err := h.readBuildInputs()
+		if err != nil {
+			t.Fatalf("%v: readBuildInputs: %v", args, err)
+		}
+		if got, want := in.Contains(devFile), args != nil; got != want {
+			t.Errorf("%v: file behind the dev tag tracked %v, want %v", args, got, want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// Attribute keys of the structured lifecycle records.
+const (
+	LogKeyMode     = "mode"      // "external" or "in-memory"
+	LogKeyFile     = "file"      // main input file, relative to AppRootDir
+	LogKeyPort     = "port"      // port the server listens on
+	LogKeyPID      = "pid"       // external server process ID
+	LogKeyBuild    = "build"     // build ID, see OutputLine.BuildID
+	LogKeyDuration = "duration"  // compile or request time
+	LogKeyExitCode = "exit_code" // exit status of a crashed external server
+	LogKeyMethod   = "method"    // HTTP method, see LogRequests
+	LogKeyPath     = "path"      // request path, see LogRequests
+	LogKeyStatus   = "status"    // response status, see LogRequests
+	LogKeyError    = "error"
+)
+
+// Values of LogKeyMode and Status.Mode.
+const (
+	ModeExternal = "external"
+	ModeInMemory = "in-memory"
+)
+
+// SetSlogHandler sends structured lifecycle records (compiles, starts, stops,
+// crashes, mode switches) to handler. Plain Logger messages are forwarded as
+// Info records. A function set with SetLog keeps receiving everything.
+func (h *ServerHandler) SetSlogHandler(handler slog.Handler) {
+	h.slog = slog.New(handler)
+}
+
+// logEvent emits a structured record with slog style key/value args, e.g.
+// h.logEvent(slog.LevelInfo, "External server started", LogKeyPort, "8080").
+// The SetLog function receives it as msg followed by "key=value" strings.
+func (h *ServerHandler) logEvent(level slog.Level, msg string, args ...any) {
+	ctx := context.Background()
+	if h.slog != nil {
+		h.slog.Log(ctx, level, msg, args...)
+	}
+	if h.log != nil {
+		slog.New(funcHandler{log: h.log}).Log(ctx, level, msg, args...)
+	}
+}
+
+// plainMessage joins variadic Logger arguments the way fmt.Println does.
+func plainMessage(messages ...any) string {
+	return strings.TrimSuffix(fmt.Sprintln(messages...), "\n")
+}
+
+// funcHandler adapts a SetLog style function to slog.Handler.
+type funcHandler struct {
+	log    func(message ...any)
+	attrs  []string // "key=value" added with WithAttrs
+	prefix string   // group prefix for keys, eg: "request."
+}
+
+func (f funcHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (f funcHandler) Handle(_ context.Context, r slog.Record) error {
+	parts := []any{r.Message}
+	for _, a := range f.attrs {
+		parts = append(parts, a)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		parts = append(parts, f.prefix+a.Key+"="+a.Value.String())
+		return true
+	})
+	f.log(parts...)
+	return nil
+}
+
+func (f funcHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := f
+	next.attrs = append([]string(nil), f.attrs...)
+	for _, a := range attrs {
+		next.attrs = append(next.attrs, f.prefix+a.Key+"="+a.Value.String())
+	}
+	return next
+}
+
+func (f funcHandler) WithGroup(name string) slog.Handler {
+	next := f
+	next.prefix = f.prefix + name + "."
+	return next
+}
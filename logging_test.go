@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordHandler collects slog records for inspection.
+type recordHandler struct {
+	mu      sync.Mutex
+	records []slog.Record
+}
+
+func (r *recordHandler) Enabled(context.Context, slog.Level) bool { return true }
+func (r *recordHandler) WithAttrs([]slog.Attr) slog.Handler       { return r }
+func (r *recordHandler) WithGroup(string) slog.Handler            { return r }
+
+func (r *recordHandler) Handle(_ context.Context, rec slog.Record) error {
+	r.mu.Lock()
+	r.records = append(r.records, rec)
+	r.mu.Unlock()
+	return nil
+}
+
+// find returns the attributes of the first record with msg.
+func (r *recordHandler) find(msg string) (map[string]slog.Value, slog.Level, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rec := range r.records {
+		if rec.Message == msg {
+			attrs := map[string]slog.Value{}
+			rec.Attrs(func(a slog.Attr) bool {
+				attrs[a.Key] = a.Value
+				return true
+			})
+			return attrs, rec.Level, true
+		}
+	}
+	return nil, 0, false
+}
+
+func TestFuncHandlerAdapter(t *testing.T) {
+	var got []any
+	logger := slog.New(funcHandler{log: func(messages ...any) { got = messages }})
+
+	logger.With(LogKeyMode, ModeExternal).WithGroup("req").Info("Started", LogKeyPort, "8080")
+
+	if fmt.Sprint(got) != "[Started mode=external req.port=8080]" {
+		t.Errorf("unexpected adapter output: %v", got)
+	}
+}
+
+func TestLifecycleRecords(t *testing.T) {
+	tmp := t.TempDir()
+	sourceDir := filepath.Join(tmp, "src", "app")
+	if err := os.MkdirAll(sourceDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	port := freePort(t)
+	if err := os.WriteFile(filepath.Join(sourceDir, "main.go"), []byte(fmt.Sprintf(fixedPortServer, port)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	records := &recordHandler{}
+	var mu sync.Mutex
+	var plain []string
+	h := New(&Config{
+		AppRootDir: tmp,
+		SourceDir:  "src/app",
+		OutputDir:  "deploy",
+		AppPort:    port,
+		ExitChan:   make(chan bool, 1),
+	})
+	h.SetSlogHandler(records)
+	h.SetLog(func(messages ...any) {
+		mu.Lock()
+		plain = append(plain, fmt.Sprintln(messages...))
+		mu.Unlock()
+	})
+
+	h.SetExternalServerMode(true)
+	h.Logger("plain", "message")
+	if err := h.strategy.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	if attrs, _, ok := records.find("Switching to External Server Mode..."); !ok || attrs[LogKeyMode].String() != ModeExternal {
+		t.Errorf("missing mode switch record: %v", attrs)
+	}
+	if attrs, _, ok := records.find("Server compiled"); !ok || attrs[LogKeyDuration].Duration() <= 0 || attrs[LogKeyBuild].Int64() != 1 {
+		t.Errorf("missing compile record with duration and build: %v", attrs)
+	}
+	started, _, ok := records.find("External server started")
+	if !ok || started[LogKeyPort].String() != port || started[LogKeyPID].Int64() <= 0 {
+		t.Fatalf("missing start record with port and pid: %v", started)
+	}
+	if attrs, _, ok := records.find("External Server stopped"); !ok || attrs[LogKeyPID].Int64() != started[LogKeyPID].Int64() {
+		t.Errorf("missing stop record with pid: %v", attrs)
+	}
+	if _, level, ok := records.find("plain message"); !ok || level != slog.LevelInfo {
+		t.Error("expected Logger messages forwarded as Info records")
+	}
+
+	// The SetLog function keeps receiving everything
+	mu.Lock()
+	all := strings.Join(plain, "")
+	mu.Unlock()
+	for _, want := range []string{"External server started mode=external", "pid=", "plain message"} {
+		if !strings.Contains(all, want) {
+			t.Errorf("SetLog output missing %q:\n%s", want, all)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
+)
+
+// chain wraps next with middleware, the first one being the outermost.
+func chain(next http.Handler, middleware []func(http.Handler) http.Handler) http.Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		if middleware[i] != nil {
+			next = middleware[i](next)
+		}
+	}
+	return next
+}
+
+// statusWriter records the status code written by a handler.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack lets websocket upgrades that assert http.Hijacker through, the
+// taken over connection counts as 101 Switching Protocols.
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil && w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// LogRequests is a middleware that logs method, path, status and duration of
+// every request, e.g. Config.Middleware = append(Config.Middleware, h.LogRequests).
+func (h *ServerHandler) LogRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		defer func() {
+			status := sw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			h.logEvent(slog.LevelInfo, "Request", LogKeyMethod, r.Method, LogKeyPath, r.URL.Path,
+				LogKeyStatus, status, LogKeyDuration, time.Since(start).Round(time.Microsecond))
+		}()
+		next.ServeHTTP(sw, r)
+	})
+}
+
+var panicTemplate = template.Must(template.ParseFS(embeddedFS, "templates/panic.html"))
+
+// Recover is a middleware that turns a handler panic into a 500 page showing
+// the panic value and stack trace, and logs it, instead of dropping the connection.
+func (h *ServerHandler) Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := &statusWriter{ResponseWriter: w}
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			stack := string(debug.Stack())
+			h.logEvent(slog.LevelError, "Handler panic", LogKeyMethod, r.Method, LogKeyPath, r.URL.Path,
+				LogKeyError, fmt.Sprint(v))
+			if sw.status != 0 {
+				// the response has started, nothing sensible can be sent
+				return
+			}
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Del("Content-Encoding")
+			w.Header().Del("Content-Length")
+			w.WriteHeader(http.StatusInternalServerError)
+			panicTemplate.Execute(w, struct {
+				Value        any
+				Method, Path string
+				Stack        string
+			}{v, r.Method, r.URL.Path, stack})
+		}()
+		next.ServeHTTP(sw, r)
+	})
+}
+
+// CORS returns a middleware that allows cross-origin requests from origins,
+// or from any origin when none (or "*") is given, and answers preflight requests.
+// Credentials are only allowed for explicitly listed origins.
+func CORS(origins ...string) func(http.Handler) http.Handler {
+	anyOrigin := len(origins) == 0 || slices.Contains(origins, "*")
+	allowed := func(origin string) bool {
+		return anyOrigin || slices.ContainsFunc(origins, func(o string) bool { return strings.EqualFold(o, origin) })
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" || !allowed(origin) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if anyOrigin {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				setVary(w.Header(), "Origin")
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+				if headers := r.Header.Get("Access-Control-Request-Headers"); headers != "" {
+					w.Header().Set("Access-Control-Allow-Headers", headers)
+				}
+				w.Header().Set("Access-Control-Max-Age", "600")
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// NoCache is a middleware that disables browser caching, as static files
+// are served by default.
+func NoCache(next http.Handler) http.Handler {
+	return noCache(next)
+}
@@ -0,0 +1,175 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInMemoryMiddlewareOrder(t *testing.T) {
+	port := freePort(t)
+	tag := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("X-Chain", name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	exit := make(chan bool, 1)
+	h := New(&Config{
+		AppRootDir: t.TempDir(),
+		AppPort:    port,
+		Routes:     []func(*http.ServeMux){textRoute("/v", "v1")},
+		Middleware: []func(http.Handler) http.Handler{tag("outer"), nil, tag("inner")},
+		ExitChan:   exit,
+	})
+	go h.StartServer(nil)
+	defer func() { exit <- true }()
+
+	getBody(t, "http://127.0.0.1:"+port+"/v")
+	resp, err := http.Get("http://127.0.0.1:" + port + "/v")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := strings.Join(resp.Header.Values("X-Chain"), ","); got != "outer,inner" {
+		t.Fatalf("expected middleware applied in order, got %q", got)
+	}
+}
+
+func TestRecoverMiddleware(t *testing.T) {
+	var logs []string
+	h := New(&Config{})
+	h.SetLog(func(messages ...any) { logs = append(logs, plainMessage(messages...)) })
+
+	boom := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { panic("boom") })
+	rec := httptest.NewRecorder()
+	h.Recover(boom).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/crash", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, "panic: boom") || !strings.Contains(body, "TestRecoverMiddleware") {
+		t.Errorf("expected panic value and stack trace in page, got %q", body)
+	}
+	if len(logs) != 1 || !strings.Contains(logs[0], "Handler panic") || !strings.Contains(logs[0], "path=/crash") {
+		t.Errorf("expected panic to be logged, got %q", logs)
+	}
+}
+
+func TestLogRequestsMiddleware(t *testing.T) {
+	var logs []string
+	h := New(&Config{})
+	h.SetLog(func(messages ...any) { logs = append(logs, plainMessage(messages...)) })
+
+	notFound := http.HandlerFunc(http.NotFound)
+	h.LogRequests(notFound).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/missing", nil))
+
+	if len(logs) != 1 {
+		t.Fatalf("expected one record, got %q", logs)
+	}
+	for _, want := range []string{"Request", "method=POST", "path=/missing", "status=404", "duration="} {
+		if !strings.Contains(logs[0], want) {
+			t.Errorf("expected %q in %q", want, logs[0])
+		}
+	}
+}
+
+func TestMiddlewareKeepsHijacker(t *testing.T) {
+	logs := make(chan string, 1)
+	h := New(&Config{})
+	h.SetLog(func(messages ...any) { logs <- plainMessage(messages...) })
+
+	// Like websocket upgrade code, take the connection over without NewResponseController
+	upgrade := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hj, ok := w.(http.Hijacker)
+		if !ok {
+			http.Error(w, "no hijacker", http.StatusInternalServerError)
+			return
+		}
+		conn, rw, err := hj.Hijack()
+		if err != nil {
+			t.Errorf("Hijack: %v", err)
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked")
+		rw.Flush()
+	})
+	srv := httptest.NewServer(chain(upgrade, []func(http.Handler) http.Handler{h.Recover, h.LogRequests, gzipHandler}))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "hijacked" {
+		t.Fatalf("expected the hijacked response, got %d %q", resp.StatusCode, body)
+	}
+	select {
+	case record := <-logs:
+		if !strings.Contains(record, "status=101") {
+			t.Errorf("expected the upgrade logged as 101, got %q", record)
+		}
+	case <-time.After(time.Second):
+		t.Error("request not logged")
+	}
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) })
+	cors := CORS("http://localhost:3000")(ok)
+
+	req := httptest.NewRequest(http.MethodOptions, "/api", nil)
+	req.Header.Set("Origin", "http://localhost:3000")
+	req.Header.Set("Access-Control-Request-Method", "PUT")
+	req.Header.Set("Access-Control-Request-Headers", "Content-Type")
+	rec := httptest.NewRecorder()
+	cors.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Origin") != "http://localhost:3000" ||
+		rec.Header().Get("Access-Control-Allow-Credentials") != "true" || rec.Header().Get("Access-Control-Allow-Headers") != "Content-Type" {
+		t.Errorf("unexpected preflight response %d %v", rec.Code, rec.Header())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api", nil)
+	req.Header.Set("Origin", "http://evil.example")
+	rec = httptest.NewRecorder()
+	cors.ServeHTTP(rec, req)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" || rec.Body.String() != "ok" {
+		t.Errorf("disallowed origin: unexpected response %q %v", rec.Body.String(), rec.Header())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api", nil)
+	req.Header.Set("Origin", "http://any.example")
+	rec = httptest.NewRecorder()
+	CORS()(ok).ServeHTTP(rec, req)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "*" || rec.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Errorf("any origin: expected * without credentials, got %v", rec.Header())
+	}
+
+	req = httptest.NewRequest(http.MethodOptions, "/api", nil)
+	req.Header.Set("Origin", "http://any.example")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	rec = httptest.NewRecorder()
+	CORS("*")(ok).ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Origin") != "*" || rec.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Errorf("any origin preflight: unexpected response %d %v", rec.Code, rec.Header())
+	}
+}
+
+func TestNoCacheMiddleware(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NoCache(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Header().Get("Cache-Control") != "no-store, no-cache, must-revalidate, max-age=0" {
+		t.Errorf("expected no-cache headers, got %v", rec.Header())
+	}
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// stderrTailLines is how many stderr lines crash reports and readiness errors include.
+const stderrTailLines = 20
+
+// Streams of OutputLine.
+const (
+	streamStdout = "stdout"
+	streamStderr = "stderr"
+)
+
+// OutputLine is a line written by the external server binary.
+type OutputLine struct {
+	Time    time.Time
+	Stream  string // "stdout" or "stderr"
+	BuildID int    // build the process was started from, increments on every successful compile
+	RunID   int    // process run that wrote the line, increments on every start of the binary (also crash restarts of the same build)
+	PID     int    // process ID of that run
+	Text    string
+}
+
+// outputBuffer keeps the last lines written by the external server and fans
+// new ones out to subscribers.
+type outputBuffer struct {
+	mu    sync.Mutex
+	lines []OutputLine // ring, oldest at next once full
+	next  int
+	full  bool
+	subs  map[chan OutputLine]struct{}
+	runs  int // last RunID handed out by newRun
+}
+
+func newOutputBuffer(size int) *outputBuffer {
+	return &outputBuffer{
+		lines: make([]OutputLine, max(size, 0)),
+		subs:  make(map[chan OutputLine]struct{}),
+	}
+}
+
+// newRun returns the RunID of a process run that is starting.
+func (b *outputBuffer) newRun() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.runs++
+	return b.runs
+}
+
+func (b *outputBuffer) add(line OutputLine) {
+	line.Time = time.Now()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.lines) > 0 {
+		b.lines[b.next] = line
+		b.next = (b.next + 1) % len(b.lines)
+		b.full = b.full || b.next == 0
+	}
+	for c := range b.subs {
+		select {
+		case c <- line:
+		default: // slow subscriber, the line stays available through Output
+		}
+	}
+}
+
+// last returns up to n lines, oldest first. n <= 0 returns every buffered line.
+func (b *outputBuffer) last(n int) []OutputLine {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	count := b.next
+	if b.full {
+		count = len(b.lines)
+	}
+	if n <= 0 || n > count {
+		n = count
+	}
+	out := make([]OutputLine, n)
+	for i := range out {
+		out[i] = b.lines[(b.next-n+i+len(b.lines))%len(b.lines)]
+	}
+	return out
+}
+
+// stderrOf returns the last stderrTailLines lines run wrote to stderr that are
+// still buffered, oldest first.
+func (b *outputBuffer) stderrOf(run int) []string {
+	var tail []string
+	for _, line := range b.last(0) {
+		if line.RunID == run && line.Stream == streamStderr {
+			tail = append(tail, line.Text)
+		}
+	}
+	return tail[max(len(tail)-stderrTailLines, 0):]
+}
+
+func (b *outputBuffer) subscribe() (<-chan OutputLine, func()) {
+	c := make(chan OutputLine, 64)
+	b.mu.Lock()
+	b.subs[c] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return c, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, c)
+			b.mu.Unlock()
+			close(c)
+		})
+	}
+}
+
+// Output returns the last n lines written by the external server, oldest
+// first, across restarts and builds. n <= 0 returns the whole buffer
+// (Config.OutputBufferLines). OutputLine.RunID tells the runs apart.
+func (h *ServerHandler) Output(n int) []OutputLine {
+	return h.output.last(n)
+}
+
+// SubscribeOutput delivers every new line written by the external server until
+// cancel is called. Lines are dropped for a subscriber that does not keep up;
+// they remain available through Output.
+func (h *ServerHandler) SubscribeOutput() (lines <-chan OutputLine, cancel func()) {
+	return h.output.subscribe()
+}
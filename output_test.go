@@ -0,0 +1,131 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOutputBufferKeepsLastLines(t *testing.T) {
+	b := newOutputBuffer(3)
+	lines, cancel := b.subscribe()
+
+	for i := 1; i <= 5; i++ {
+		b.add(OutputLine{Stream: streamStdout, BuildID: 1, Text: fmt.Sprint("line", i)})
+	}
+
+	var got []string
+	for _, l := range b.last(0) {
+		got = append(got, l.Text)
+	}
+	if strings.Join(got, ",") != "line3,line4,line5" {
+		t.Errorf("unexpected buffer content: %v", got)
+	}
+	if last := b.last(1); len(last) != 1 || last[0].Text != "line5" {
+		t.Errorf("unexpected last line: %+v", last)
+	}
+
+	for i := 1; i <= 5; i++ {
+		if l := <-lines; l.Text != fmt.Sprint("line", i) {
+			t.Errorf("subscriber got %q, want line%d", l.Text, i)
+		}
+	}
+	cancel()
+	cancel() // idempotent
+	if _, open := <-lines; open {
+		t.Error("expected channel closed after cancel")
+	}
+}
+
+func TestOutputBufferStderrOfRun(t *testing.T) {
+	b := newOutputBuffer(100)
+	b.add(OutputLine{Stream: streamStderr, RunID: 1, Text: "previous run"})
+	b.add(OutputLine{Stream: streamStdout, RunID: 2, Text: "to stdout"})
+	for i := 1; i <= stderrTailLines+5; i++ {
+		b.add(OutputLine{Stream: streamStderr, RunID: 2, Text: fmt.Sprint("line", i)})
+	}
+
+	tail := b.stderrOf(2)
+	if len(tail) != stderrTailLines || tail[0] != "line6" || tail[len(tail)-1] != fmt.Sprint("line", stderrTailLines+5) {
+		t.Errorf("unexpected stderr of run 2: %v", tail)
+	}
+	if got := b.stderrOf(1); len(got) != 1 || got[0] != "previous run" {
+		t.Errorf("unexpected stderr of run 1: %v", got)
+	}
+}
+
+func TestOutputTaggedWithStreamAndBuild(t *testing.T) {
+	tmp := t.TempDir()
+	sourceDir := filepath.Join(tmp, "src", "app")
+	if err := os.MkdirAll(sourceDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	port := freePort(t)
+	mainFile := filepath.Join(sourceDir, "main.go")
+	content := strings.Replace(fmt.Sprintf(fixedPortServer, port), "func main() {", "func main() {\n\tfmt.Println(\"to stdout\")\n\tfmt.Fprintln(os.Stderr, \"to stderr\")", 1)
+	content = strings.Replace(content, "\"net/http\"", "\"net/http\"\n\t\"os\"", 1)
+	if err := os.WriteFile(mainFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	h := New(&Config{
+		AppRootDir: tmp,
+		SourceDir:  "src/app",
+		OutputDir:  "deploy",
+		AppPort:    port,
+		ExitChan:   make(chan bool, 1),
+	})
+	h.SetExternalServerMode(true)
+	defer h.strategy.Stop()
+
+	// waitOutput returns the buffered lines once want lines were written.
+	waitOutput := func(want int) []OutputLine {
+		t.Helper()
+		deadline := time.Now().Add(3 * time.Second)
+		for {
+			out := h.Output(0)
+			if len(out) >= want || time.Now().After(deadline) {
+				return out
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+
+	out := waitOutput(2)
+	if len(out) != 2 {
+		t.Fatalf("expected 2 lines, got %+v", out)
+	}
+	streams := map[string]string{}
+	for _, l := range out {
+		streams[l.Stream] = l.Text
+		if l.BuildID != 1 || l.Time.IsZero() {
+			t.Errorf("unexpected tags: %+v", l)
+		}
+	}
+	if streams["stdout"] != "to stdout" || streams["stderr"] != "to stderr" {
+		t.Errorf("unexpected streams: %v", streams)
+	}
+
+	lines, cancel := h.SubscribeOutput()
+	defer cancel()
+	if err := os.WriteFile(mainFile, []byte(content+"\n// rebuilt\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.NewFileEvent("main.go", ".go", mainFile, "write"); err != nil {
+		t.Fatalf("NewFileEvent: %v", err)
+	}
+	select {
+	case l := <-lines:
+		if l.BuildID != 2 {
+			t.Errorf("expected line from build 2, got %+v", l)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("no output delivered to subscriber")
+	}
+	if last := waitOutput(4); len(last) != 4 || last[0].BuildID != 1 || last[3].BuildID != 2 {
+		t.Errorf("expected history across builds, got %+v", last)
+	}
+}
@@ -0,0 +1,192 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Diagnostic is a compiler message pointing at a source location.
+type Diagnostic struct {
+	File    string // absolute path of the source file
+	Line    int
+	Column  int // 0 when the compiler did not report one
+	Message string
+}
+
+// diagnosticLine matches go build output such as "../src/app/main.go:6:6: undefined: fmt.rintf"
+var diagnosticLine = regexp.MustCompile(`(?m)^(\S+\.go):(\d+)(?::(\d+))?: (.+)$`)
+
+// parseDiagnostics extracts source locations from go build output.
+// Relative paths are resolved against dir, the directory the compiler ran in.
+func parseDiagnostics(output, dir string) []Diagnostic {
+	var diags []Diagnostic
+	for _, m := range diagnosticLine.FindAllStringSubmatch(output, -1) {
+		file := m[1]
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		line, _ := strconv.Atoi(m[2])
+		col, _ := strconv.Atoi(m[3])
+		diags = append(diags, Diagnostic{
+			File:    filepath.Clean(file),
+			Line:    line,
+			Column:  col,
+			Message: m[4],
+		})
+	}
+	return diags
+}
+
+// snippetContext is the number of source lines shown around a diagnostic.
+const snippetContext = 3
+
+type snippetLine struct {
+	Number int
+	Text   string
+	Error  bool
+}
+
+type overlayDiagnostic struct {
+	Diagnostic
+	Location string
+	Link     template.URL
+	Snippet  []snippetLine
+}
+
+// readSnippet returns the lines around line in file, or nil if it cannot be read.
+func readSnippet(file string, line int) []snippetLine {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var out []snippetLine
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan() && n <= line+snippetContext; n++ {
+		if n >= line-snippetContext {
+			out = append(out, snippetLine{Number: n, Text: strings.ReplaceAll(sc.Text(), "\t", "    "), Error: n == line})
+		}
+	}
+	return out
+}
+
+var overlayTemplate = template.Must(template.ParseFS(embeddedFS, "templates/error_overlay.html"))
+
+// errorOverlay serves a page with the compiler output while the external build is broken.
+// Without ProxyMode it binds AppPort itself, in ProxyMode the proxy renders it.
+type errorOverlay struct {
+	handler *ServerHandler
+
+	mu     sync.Mutex
+	server *http.Server
+	title  string // eg: Build failed
+	output string
+	diags  []Diagnostic
+}
+
+// Set records the output of the failed build or crashed server.
+func (o *errorOverlay) Set(title, output string) {
+	diags := parseDiagnostics(output, filepath.Join(o.handler.AppRootDir, o.handler.OutputDir))
+	o.mu.Lock()
+	o.title = title
+	o.output = output
+	o.diags = diags
+	o.mu.Unlock()
+}
+
+// Clear forgets the failed build and closes the AppPort listener if open.
+func (o *errorOverlay) Clear() error {
+	o.mu.Lock()
+	o.output = ""
+	o.diags = nil
+	srv := o.server
+	o.server = nil
+	o.mu.Unlock()
+
+	if srv == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	return srv.Shutdown(ctx)
+}
+
+func (o *errorOverlay) Active() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.output != ""
+}
+
+// Listen serves the overlay on AppPort until Clear is called.
+func (o *errorOverlay) Listen() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.server != nil {
+		return nil
+	}
+
+	ln, err := o.handler.listenAppPort()
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: o.handler.liveReload(o)}
+	srv.RegisterOnShutdown(o.handler.reload.closeAll)
+	o.server = srv
+	go srv.Serve(ln)
+	return nil
+}
+
+func (o *errorOverlay) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	o.mu.Lock()
+	title, output, diags := o.title, o.output, o.diags
+	o.mu.Unlock()
+
+	data := struct {
+		Title       string
+		Output      string
+		Diagnostics []overlayDiagnostic
+	}{Title: title, Output: output}
+
+	for _, d := range diags {
+		loc := d.File + ":" + strconv.Itoa(d.Line)
+		if d.Column > 0 {
+			loc += ":" + strconv.Itoa(d.Column)
+		}
+		data.Diagnostics = append(data.Diagnostics, overlayDiagnostic{
+			Diagnostic: d,
+			Location:   loc,
+			Link:       template.URL(o.handler.editorLink(d)),
+			Snippet:    readSnippet(d.File, d.Line),
+		})
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusInternalServerError)
+	if err := overlayTemplate.Execute(w, data); err != nil {
+		o.handler.Logger("Error overlay template:", err)
+	}
+}
+
+// editorLink fills Config.EditorURL with the diagnostic location.
+func (h *ServerHandler) editorLink(d Diagnostic) string {
+	col := d.Column
+	if col == 0 {
+		col = 1
+	}
+	return strings.NewReplacer(
+		"{file}", filepath.ToSlash(d.File),
+		"{line}", strconv.Itoa(d.Line),
+		"{col}", strconv.Itoa(col),
+	).Replace(h.EditorURL)
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseDiagnostics(t *testing.T) {
+	output := `compileSync build failed: exit status 1 # command-line-arguments
+../src/app/main.go:6:6: undefined: fmt.rintf
+../src/app/main.go:7:14: cannot use "s" (untyped string constant) as int value in variable declaration
+/abs/handlers.go:12: something else`
+
+	diags := parseDiagnostics(output, "/project/deploy")
+	if len(diags) != 3 {
+		t.Fatalf("expected 3 diagnostics, got %d: %+v", len(diags), diags)
+	}
+	want := Diagnostic{File: filepath.Clean("/project/src/app/main.go"), Line: 6, Column: 6, Message: "undefined: fmt.rintf"}
+	if diags[0] != want {
+		t.Errorf("got %+v, want %+v", diags[0], want)
+	}
+	if diags[2].File != "/abs/handlers.go" || diags[2].Line != 12 || diags[2].Column != 0 {
+		t.Errorf("unexpected diagnostic without column: %+v", diags[2])
+	}
+}
+
+// fixedPortServer listens on a port baked into the source.
+const fixedPortServer = `package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+func main() {
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "APP_OK")
+	})
+	http.ListenAndServe("127.0.0.1:%s", nil)
+}
+`
+
+func TestErrorOverlayServedWhileBuildIsBroken(t *testing.T) {
+	tmp := t.TempDir()
+	sourceDir := filepath.Join(tmp, "src", "app")
+	if err := os.MkdirAll(sourceDir, 0755); err != nil {
+		t.Fatalf("creating source directory: %v", err)
+	}
+	port := freePort(t)
+	mainFile := filepath.Join(sourceDir, "main.go")
+	good := fmt.Sprintf(fixedPortServer, port)
+	broken := strings.Replace(good, "fmt.Fprint(w", "fmt.Fprintz(w", 1)
+	if err := os.WriteFile(mainFile, []byte(broken), 0644); err != nil {
+		t.Fatalf("writing broken file: %v", err)
+	}
+
+	h := New(&Config{
+		AppRootDir: tmp,
+		SourceDir:  "src/app",
+		OutputDir:  "deploy",
+		AppPort:    port,
+		ExitChan:   make(chan bool, 1),
+	})
+	// No previous build can serve, the error page takes AppPort
+	h.SetExternalServerMode(true)
+	defer h.strategy.Stop()
+
+	base := "http://127.0.0.1:" + port
+	status, body := getBody(t, base)
+	if status != http.StatusInternalServerError {
+		t.Fatalf("expected error page status 500, got %d: %s", status, body)
+	}
+	for _, want := range []string{
+		"Build failed",
+		"undefined: fmt.Fprintz",
+		"vscode://file/" + filepath.ToSlash(mainFile) + ":10:",
+		`class="line error"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("error page missing %q", want)
+		}
+	}
+
+	if err := os.WriteFile(mainFile, []byte(good), 0644); err != nil {
+		t.Fatalf("writing fixed file: %v", err)
+	}
+	if err := h.NewFileEvent("main.go", ".go", mainFile, "write"); err != nil {
+		t.Fatalf("expected restart to succeed after fix: %v", err)
+	}
+	if status, body := getBody(t, base); status != http.StatusOK || body != "APP_OK" {
+		t.Fatalf("expected app after fix, got %d %q", status, body)
+	}
+}
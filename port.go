@@ -0,0 +1,92 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+)
+
+// PortPolicy decides what happens when AppPort is already in use.
+type PortPolicy int
+
+const (
+	PortStrict PortPolicy = iota // fail with the bind error (default)
+	PortNext                     // try the following ports, up to portSearchRange
+	PortAny                      // let the OS assign a free port
+)
+
+// portSearchRange is how many ports after AppPort PortNext tries.
+const portSearchRange = 20
+
+// listenAppPort binds AppPort, or another port following Config.PortPolicy,
+// and records it as the port in use (see Port).
+func (h *ServerHandler) listenAppPort() (net.Listener, error) {
+	ln, err := net.Listen("tcp", ":"+h.AppPort)
+	if err != nil && h.PortPolicy != PortStrict {
+		ln = h.listenFallbackPort()
+	}
+	if ln == nil {
+		return nil, err
+	}
+
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		h.logEvent(slog.LevelWarn, "AppPort "+h.AppPort+" in use, using port "+port, LogKeyPort, port, LogKeyError, err)
+	}
+	h.setPort(port)
+	return ln, nil
+}
+
+// listenFallbackPort binds a port other than AppPort, nil if none is free.
+func (h *ServerHandler) listenFallbackPort() net.Listener {
+	if h.PortPolicy == PortAny {
+		ln, _ := net.Listen("tcp", ":0")
+		return ln
+	}
+
+	base, err := strconv.Atoi(h.AppPort)
+	if err != nil {
+		return nil
+	}
+	for p := base + 1; p <= base+portSearchRange && p <= 65535; p++ {
+		if ln, err := net.Listen("tcp", ":"+strconv.Itoa(p)); err == nil {
+			return ln
+		}
+	}
+	return nil
+}
+
+// reserveAppPort picks the port the external binary will bind and releases it
+// again, so the binary can take it. For AppPort "0" the port picked before is
+// kept while it is free, so the URL survives rebuilds.
+func (h *ServerHandler) reserveAppPort() (string, error) {
+	if picked := h.Port(); h.AppPort == "0" && picked != "0" {
+		if ln, err := net.Listen("tcp", ":"+picked); err == nil {
+			return picked, ln.Close()
+		}
+	}
+	ln, err := h.listenAppPort()
+	if err != nil {
+		return "", fmt.Errorf("no free port for the server: %w", err)
+	}
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	return port, ln.Close()
+}
+
+func (h *ServerHandler) setPort(port string) {
+	h.portMu.Lock()
+	h.port = port
+	h.portMu.Unlock()
+}
+
+// Port returns the port the server is reachable on. It differs from AppPort
+// when Config.PortPolicy picked another one, or AppPort is "0".
+func (h *ServerHandler) Port() string {
+	h.portMu.Lock()
+	defer h.portMu.Unlock()
+	if h.port == "" {
+		return h.AppPort
+	}
+	return h.port
+}
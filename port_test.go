@@ -0,0 +1,169 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// occupyPort keeps port busy until the test ends.
+func occupyPort(t *testing.T, port string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		t.Fatalf("occupying port %s: %v", port, err)
+	}
+	t.Cleanup(func() { ln.Close() })
+}
+
+func TestInMemoryStartReturnsBindError(t *testing.T) {
+	port := freePort(t)
+	occupyPort(t, port)
+
+	h := New(&Config{AppPort: port, ExitChan: make(chan bool, 1)})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	done := make(chan error, 1)
+	go func() { done <- h.strategy.Start(&wg) }()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected bind error from Start")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start did not return on a busy port")
+	}
+	wg.Wait()
+}
+
+func TestPortPolicyPicksFreePort(t *testing.T) {
+	for name, policy := range map[string]PortPolicy{"next": PortNext, "any": PortAny} {
+		t.Run(name, func(t *testing.T) {
+			port := freePort(t)
+			occupyPort(t, port)
+
+			exit := make(chan bool, 1)
+			h := New(&Config{
+				AppPort:    port,
+				PortPolicy: policy,
+				ExitChan:   exit,
+				Routes:     []func(*http.ServeMux){textRoute("/hello", "hi")},
+			})
+			go h.StartServer(nil)
+			defer func() { exit <- true }()
+
+			deadline := time.Now().Add(5 * time.Second)
+			for h.Port() == port && time.Now().Before(deadline) {
+				time.Sleep(20 * time.Millisecond)
+			}
+			if h.Port() == port {
+				t.Fatalf("Port() still reports busy port %s", port)
+			}
+			if _, body := getBody(t, "http://127.0.0.1:"+h.Port()+"/hello"); body != "hi" {
+				t.Fatalf("expected hi on port %s, got %q", h.Port(), body)
+			}
+		})
+	}
+}
+
+func TestOSAssignedPortIsReported(t *testing.T) {
+	exit := make(chan bool, 1)
+	h := New(&Config{
+		AppPort:  "0",
+		ExitChan: exit,
+		Routes:   []func(*http.ServeMux){textRoute("/hello", "hi")},
+	})
+	go h.StartServer(nil)
+	defer func() { exit <- true }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for h.Port() == "0" && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if _, body := getBody(t, "http://127.0.0.1:"+h.Port()+"/hello"); body != "hi" {
+		t.Fatalf("expected hi on port %s, got %q", h.Port(), body)
+	}
+}
+
+func TestExternalServerReceivesPickedPort(t *testing.T) {
+	tmp := t.TempDir()
+	sourceDir := filepath.Join(tmp, "src", "app")
+	if err := os.MkdirAll(sourceDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sourceDir, "main.go"), []byte(fmt.Sprintf(portServer, "picked")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	port := freePort(t)
+	occupyPort(t, port)
+
+	h := New(&Config{
+		AppRootDir: tmp,
+		SourceDir:  "src/app",
+		OutputDir:  "deploy",
+		AppPort:    port,
+		PortPolicy: PortNext,
+		ExitChan:   make(chan bool, 1),
+	})
+	h.SetExternalServerMode(true)
+	defer h.strategy.Stop()
+
+	if h.Port() == port {
+		t.Fatalf("Port() still reports busy port %s", port)
+	}
+	if _, body := getBody(t, "http://127.0.0.1:"+h.Port()+"/v"); body != "picked" {
+		t.Fatalf("expected external server on port %s, got %q", h.Port(), body)
+	}
+}
+
+func TestExternalServerReceivesOSAssignedPort(t *testing.T) {
+	tmp := t.TempDir()
+	sourceDir := filepath.Join(tmp, "src", "app")
+	if err := os.MkdirAll(sourceDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	mainFile := filepath.Join(sourceDir, "main.go")
+	if err := os.WriteFile(mainFile, []byte(fmt.Sprintf(portServer, "assigned")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	h := New(&Config{
+		AppRootDir: tmp,
+		SourceDir:  "src/app",
+		OutputDir:  "deploy",
+		AppPort:    "0",
+		ExitChan:   make(chan bool, 1),
+	})
+	h.SetExternalServerMode(true)
+	defer h.strategy.Stop()
+
+	port := h.Port()
+	if port == "0" || h.Status().Addr != ":"+port {
+		t.Fatalf("expected the assigned port to be reported, Port() %s, Status().Addr %s", port, h.Status().Addr)
+	}
+	// Readiness waited for the binary on that port
+	resp, err := http.Get("http://127.0.0.1:" + port + "/v")
+	if err != nil {
+		t.Fatalf("expected external server ready on port %s: %v", port, err)
+	}
+	resp.Body.Close()
+
+	// A rebuild keeps the port
+	if err := os.WriteFile(mainFile, []byte(fmt.Sprintf(portServer, "rebuilt")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.NewFileEvent("main.go", ".go", mainFile, "write"); err != nil {
+		t.Fatalf("NewFileEvent: %v", err)
+	}
+	if _, body := getBody(t, "http://127.0.0.1:"+port+"/v"); body != "rebuilt" || h.Port() != port {
+		t.Fatalf("expected rebuilt server on port %s, got %q on %s", port, body, h.Port())
+	}
+}
@@ -0,0 +1,291 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// serverProcess runs the compiled server binary as a child process.
+// Unlike gorun, it can be stopped on its own without sending to the shared
+// Config.ExitChan, which would shut down the whole dev environment.
+type serverProcess struct {
+	execPath   string          // eg: ./main (relative to workingDir)
+	workingDir string          // eg: /home/user/project/deploy
+	args       func() []string // arguments passed to the binary
+	env        func() []string // extra environment variables eg: PORT=8080
+	logger     func(message ...any)
+	output     func(run, pid int, stream, line string) // optional, receives every line written by the binary
+	nextRun    func() int                              // optional, numbers the runs passed to output
+	exited     func(pid int, err error)                // optional, called when a run ends with its cmd.Wait error
+
+	mu      sync.Mutex
+	cmd     *exec.Cmd
+	done    chan struct{} // closed when cmd exits
+	exitErr error         // result of cmd.Wait once done is closed
+	stopped bool          // Stop was called for the current run
+	started time.Time     // when the current run was started
+	run     int           // number of the current run, from nextRun
+}
+
+func (p *serverProcess) Running() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cmd == nil {
+		return false
+	}
+	select {
+	case <-p.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Started reports whether the process was ever started, running or not.
+func (p *serverProcess) Started() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.cmd != nil
+}
+
+// Start launches the binary. It fails if a previous run is still alive.
+func (p *serverProcess) Start() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cmd != nil {
+		select {
+		case <-p.done:
+		default:
+			return errors.New("server process already running")
+		}
+	}
+
+	var args []string
+	if p.args != nil {
+		args = p.args()
+	}
+
+	cmd := exec.Command(p.execPath, args...)
+	cmd.Dir = p.workingDir
+	if p.env != nil {
+		if env := p.env(); len(env) > 0 {
+			cmd.Env = append(os.Environ(), env...)
+		}
+	}
+	cmd.Stdout = &lineWriter{emit: func(line string) {
+		p.record(streamStdout, line)
+		p.log(line)
+	}}
+	cmd.Stderr = &lineWriter{emit: func(line string) {
+		p.record(streamStderr, line)
+		p.log(line)
+	}}
+	// Do not hang on Wait if the binary leaves children holding its output pipes
+	cmd.WaitDelay = time.Second
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	p.cmd = cmd
+	p.done = done
+	p.exitErr = nil
+	p.stopped = false
+	p.started = time.Now()
+	if p.nextRun != nil {
+		p.run = p.nextRun()
+	}
+
+	go func() {
+		err := cmd.Wait()
+		cmd.Stdout.(*lineWriter).Flush()
+		cmd.Stderr.(*lineWriter).Flush()
+		if err != nil && p.logger != nil {
+			p.logger("Server process exited:", err)
+		}
+		p.mu.Lock()
+		if p.cmd == cmd {
+			p.exitErr = err
+		}
+		p.mu.Unlock()
+		close(done)
+		if p.exited != nil {
+			p.exited(cmd.Process.Pid, err)
+		}
+	}()
+
+	return nil
+}
+
+// Exited reports whether the current run has ended and, if so, its exit error.
+func (p *serverProcess) Exited() (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cmd == nil {
+		return false, nil
+	}
+	select {
+	case <-p.done:
+		return true, p.exitErr
+	default:
+		return false, nil
+	}
+}
+
+// PID returns the process ID of the current run, 0 if never started.
+func (p *serverProcess) PID() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cmd == nil || p.cmd.Process == nil {
+		return 0
+	}
+	return p.cmd.Process.Pid
+}
+
+// StartedAt returns when the current run was started, zero if never started.
+func (p *serverProcess) StartedAt() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.started
+}
+
+// Done returns a channel closed when the current run ends, nil if never started.
+func (p *serverProcess) Done() <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.done
+}
+
+// StopRequested reports whether the current run was ended by Stop rather than on its own.
+func (p *serverProcess) StopRequested() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stopped
+}
+
+// Run returns the number of the current run, the RunID of its output lines.
+func (p *serverProcess) Run() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.run
+}
+
+// record passes line to output, tagged with the run that wrote it. Lines of a
+// run are flushed before the next run can start.
+func (p *serverProcess) record(stream, line string) {
+	if p.output == nil {
+		return
+	}
+	p.mu.Lock()
+	run, pid := p.run, p.cmd.Process.Pid
+	p.mu.Unlock()
+	p.output(run, pid, stream, line)
+}
+
+func (p *serverProcess) log(line string) {
+	if p.logger != nil {
+		p.logger(line)
+	}
+}
+
+// Stop sends SIGTERM, waits up to grace for the process to exit and then sends SIGKILL.
+func (p *serverProcess) Stop(grace time.Duration) error {
+	p.mu.Lock()
+	cmd, done := p.cmd, p.done
+	if cmd != nil {
+		p.stopped = true
+	}
+	p.mu.Unlock()
+
+	if cmd == nil {
+		return nil
+	}
+	return p.stop(cmd, done, grace)
+}
+
+func (p *serverProcess) stop(cmd *exec.Cmd, done chan struct{}, grace time.Duration) error {
+	select {
+	case <-done:
+		return nil
+	default:
+	}
+
+	// Windows does not support SIGTERM, Signal returns an error and we fall through to Kill.
+	if err := cmd.Process.Signal(syscall.SIGTERM); err == nil {
+		select {
+		case <-done:
+			return nil
+		case <-time.After(grace):
+			if p.logger != nil {
+				p.logger("Server process did not exit after", grace, "sending SIGKILL")
+			}
+		}
+	}
+
+	if err := cmd.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return fmt.Errorf("killing server process: %w", err)
+	}
+	<-done
+	return nil
+}
+
+// waitPortFree blocks until the port can be bound again or the timeout expires.
+// Ports "" and "0" are never considered busy.
+func waitPortFree(port string, timeout time.Duration) error {
+	if port == "" || port == "0" {
+		return nil
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		ln, err := net.Listen("tcp", ":"+port)
+		if err == nil {
+			return ln.Close()
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("port %s still in use after %v: %w", port, timeout, err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// lineWriter forwards complete lines of process output to emit.
+type lineWriter struct {
+	mu   sync.Mutex
+	buf  []byte
+	emit func(line string)
+}
+
+func (w *lineWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, b...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		line := string(bytes.TrimRight(w.buf[:i], "\r"))
+		w.buf = w.buf[i+1:]
+		w.emit(line)
+	}
+	return len(b), nil
+}
+
+// Flush emits any trailing output that did not end with a newline.
+func (w *lineWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buf) > 0 {
+		w.emit(string(w.buf))
+	}
+	w.buf = nil
+}
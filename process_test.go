@@ -0,0 +1,121 @@
+package server
+
+import (
+	"net"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestServerProcessStop(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses unix shell commands")
+	}
+
+	p := &serverProcess{
+		execPath: "sleep",
+		args:     func() []string { return []string{"30"} },
+	}
+
+	if err := p.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if !p.Running() {
+		t.Fatal("expected process to be running")
+	}
+
+	start := time.Now()
+	if err := p.Stop(time.Second); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+	if p.Running() {
+		t.Fatal("expected process to be stopped")
+	}
+	if elapsed := time.Since(start); elapsed > 900*time.Millisecond {
+		t.Errorf("SIGTERM should end sleep immediately, took %v", elapsed)
+	}
+}
+
+func TestServerProcessKillsAfterGracePeriod(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses unix shell commands")
+	}
+
+	p := &serverProcess{
+		execPath: "sh",
+		args:     func() []string { return []string{"-c", `trap "" TERM; exec sleep 30`} },
+	}
+	if err := p.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	// give the shell time to install the trap
+	time.Sleep(200 * time.Millisecond)
+
+	grace := 300 * time.Millisecond
+	start := time.Now()
+	if err := p.Stop(grace); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < grace {
+		t.Errorf("expected to wait the grace period before SIGKILL, took %v", elapsed)
+	}
+	if p.Running() {
+		t.Fatal("expected process to be killed")
+	}
+}
+
+func TestWaitPortFree(t *testing.T) {
+	ln, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	if err := waitPortFree(port, 100*time.Millisecond); err == nil {
+		t.Fatal("expected error while port is busy")
+	}
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		ln.Close()
+	}()
+	if err := waitPortFree(port, 2*time.Second); err != nil {
+		t.Fatalf("expected port to be released: %v", err)
+	}
+}
+
+func TestServerProcessOutputTaggedWithRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses unix shell commands")
+	}
+
+	b := newOutputBuffer(10)
+	p := &serverProcess{
+		execPath: "sh",
+		args:     func() []string { return []string{"-c", "echo hi"} },
+		output: func(run, pid int, stream, line string) {
+			b.add(OutputLine{Stream: stream, RunID: run, PID: pid, Text: line})
+		},
+		nextRun: b.newRun,
+	}
+
+	// Same binary started twice, as after a crash
+	var pids []int
+	for range 2 {
+		if err := p.Start(); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+		pids = append(pids, p.PID())
+		<-p.Done()
+	}
+
+	out := b.last(0)
+	if len(out) != 2 {
+		t.Fatalf("expected 2 lines, got %+v", out)
+	}
+	for i, l := range out {
+		if l.RunID != i+1 || l.PID != pids[i] {
+			t.Errorf("line %d: expected run %d of pid %d, got %+v", i, i+1, pids[i], l)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// frontProxy owns AppPort in external ProxyMode, or after a handover without
+// it, and forwards requests to the compiled server, which listens on an
+// internal port. While a restart is in progress requests are held until the
+// new binary is ready.
+type frontProxy struct {
+	handler *ServerHandler
+	overlay *errorOverlay
+
+	mu     sync.Mutex
+	server *http.Server
+	gate   chan struct{}          // closed when target (or err) may be used
+	target *httputil.ReverseProxy // nil until the first successful start
+	err    error                  // reason target is unavailable
+}
+
+func newFrontProxy(h *ServerHandler, overlay *errorOverlay) *frontProxy {
+	return &frontProxy{
+		handler: h,
+		overlay: overlay,
+		gate:    make(chan struct{}),
+	}
+}
+
+// Start binds AppPort (see Config.PortPolicy). It is a no-op if the proxy is already listening.
+func (p *frontProxy) Start() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.server != nil {
+		return nil
+	}
+
+	ln, err := p.handler.listenAppPort()
+	if err != nil {
+		return err
+	}
+	p.serve(ln)
+	return nil
+}
+
+// Take binds port as soon as the server listening on it directly let go,
+// retrying until timeout, and serves on it.
+func (p *frontProxy) Take(port string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		ln, err := net.Listen("tcp", ":"+port)
+		if err == nil {
+			p.mu.Lock()
+			p.serve(ln)
+			p.mu.Unlock()
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("port %s still in use after %v: %w", port, timeout, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// serve starts proxying on ln. Must be called with p.mu held.
+func (p *frontProxy) serve(ln net.Listener) {
+	srv := &http.Server{Handler: p.handler.liveReload(p)}
+	srv.RegisterOnShutdown(p.handler.reload.closeAll)
+	p.server = srv
+	p.handler.Logger("Proxy listening on", ln.Addr().String())
+
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			p.handler.Logger("Proxy error:", err)
+		}
+	}()
+}
+
+// Stop closes the listener and waits for open requests until ctx ends, then
+// closes the connections still open.
+func (p *frontProxy) Stop(ctx context.Context) error {
+	p.mu.Lock()
+	srv := p.server
+	p.server = nil
+	p.mu.Unlock()
+
+	if srv == nil {
+		return nil
+	}
+
+	err := srv.Shutdown(ctx)
+	if err != nil {
+		srv.Close()
+	}
+	return err
+}
+
+// Hold makes new requests wait until Release or Fail is called.
+func (p *frontProxy) Hold() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	select {
+	case <-p.gate:
+		p.gate = make(chan struct{})
+	default:
+	}
+}
+
+// Release points the proxy at the server listening on port and lets held requests through.
+func (p *frontProxy) Release(port string) {
+	target := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: net.JoinHostPort("127.0.0.1", port)})
+	target.FlushInterval = -1 // do not buffer streamed responses
+	target.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		p.handler.Logger("Proxy error:", r.URL.Path, err)
+		http.Error(w, "server unavailable: "+err.Error(), http.StatusBadGateway)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.target = target
+	p.err = nil
+	p.open()
+}
+
+// Fail lets held requests through with err (or the error overlay) as response,
+// used when no binary could be built or started.
+func (p *frontProxy) Fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.target = nil
+	p.err = err
+	p.open()
+}
+
+// open closes the gate. Must be called with p.mu held.
+func (p *frontProxy) open() {
+	select {
+	case <-p.gate:
+	default:
+		close(p.gate)
+	}
+}
+
+func (p *frontProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	gate := p.gate
+	p.mu.Unlock()
+
+	select {
+	case <-gate:
+	case <-r.Context().Done():
+		return
+	}
+
+	p.mu.Lock()
+	target, err := p.target, p.err
+	p.mu.Unlock()
+
+	if target == nil {
+		if p.overlay != nil && p.overlay.Active() {
+			p.overlay.ServeHTTP(w, r)
+			return
+		}
+		if err == nil {
+			err = errors.New("server not started")
+		}
+		http.Error(w, "server unavailable: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	target.ServeHTTP(w, r)
+}
+
+// freeLocalPort asks the OS for a port that is free right now.
+func freeLocalPort() (string, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer ln.Close()
+	_, port, err := net.SplitHostPort(ln.Addr().String())
+	return port, err
+}
@@ -0,0 +1,398 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// portServer is a minimal external server honoring the -port flag passed in ProxyMode.
+const portServer = `package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+func main() {
+	port := flag.String("port", "", "")
+	flag.Parse()
+	http.HandleFunc("/v", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "%s")
+	})
+	time.Sleep(200 * time.Millisecond) // slow bind to exercise held requests
+	http.ListenAndServe("127.0.0.1:"+*port, nil)
+}
+`
+
+func TestProxyModeHoldsRequestsDuringRestart(t *testing.T) {
+	tmp := t.TempDir()
+	sourceDir := filepath.Join(tmp, "src", "app")
+	if err := os.MkdirAll(sourceDir, 0755); err != nil {
+		t.Fatalf("creating source directory: %v", err)
+	}
+	mainFile := filepath.Join(sourceDir, "main.go")
+	if err := os.WriteFile(mainFile, []byte(fmt.Sprintf(portServer, "v1")), 0644); err != nil {
+		t.Fatalf("writing server file: %v", err)
+	}
+
+	port := freePort(t)
+	exit := make(chan bool, 1)
+	cfg := &Config{
+		AppRootDir: tmp,
+		SourceDir:  "src/app",
+		OutputDir:  "deploy",
+		AppPort:    port,
+		ProxyMode:  true,
+		ExitChan:   exit,
+	}
+	h := New(cfg)
+	h.SetExternalServerMode(true)
+	defer h.strategy.Stop()
+
+	base := "http://127.0.0.1:" + port
+	if _, body := getBody(t, base+"/v"); body != "v1" {
+		t.Fatalf("expected v1 through proxy, got %q", body)
+	}
+
+	if err := os.WriteFile(mainFile, []byte(fmt.Sprintf(portServer, "v2")), 0644); err != nil {
+		t.Fatalf("writing server file: %v", err)
+	}
+
+	// Keep requesting while the restart happens, none of them may fail
+	stop := make(chan struct{})
+	failures := make(chan string, 100)
+	go func() {
+		client := &http.Client{Timeout: 30 * time.Second}
+		for {
+			select {
+			case <-stop:
+				close(failures)
+				return
+			default:
+			}
+			failure := ""
+			resp, err := client.Get(base + "/v")
+			if err != nil {
+				failure = err.Error()
+			} else {
+				b, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusOK {
+					failure = fmt.Sprintf("%d %s", resp.StatusCode, b)
+				}
+			}
+			if failure != "" {
+				select {
+				case failures <- failure:
+				default:
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	if err := h.NewFileEvent("main.go", ".go", mainFile, "write"); err != nil {
+		t.Fatalf("NewFileEvent: %v", err)
+	}
+	close(stop)
+
+	var failed []string
+	for f := range failures {
+		failed = append(failed, f)
+	}
+	if len(failed) > 0 {
+		t.Errorf("requests failed during restart: %s", strings.Join(failed, "; "))
+	}
+
+	if _, body := getBody(t, base+"/v"); body != "v2" {
+		t.Fatalf("expected v2 through proxy, got %q", body)
+	}
+
+	// Stopping frees AppPort without using ExitChan
+	if err := h.strategy.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if err := waitPortFree(port, time.Second); err != nil {
+		t.Errorf("expected AppPort to be free after switching mode: %v", err)
+	}
+	select {
+	case <-exit:
+		t.Error("Stop must not signal ExitChan")
+	default:
+	}
+}
+
+func TestReplacedExternalStrategyIgnoresLateEvents(t *testing.T) {
+	tmp := t.TempDir()
+	sourceDir := filepath.Join(tmp, "src", "app")
+	if err := os.MkdirAll(sourceDir, 0755); err != nil {
+		t.Fatalf("creating source directory: %v", err)
+	}
+	mainFile := filepath.Join(sourceDir, "main.go")
+	if err := os.WriteFile(mainFile, []byte(fmt.Sprintf(portServer, "v1")), 0644); err != nil {
+		t.Fatalf("writing server file: %v", err)
+	}
+
+	port := freePort(t)
+	exit := make(chan bool, 1)
+	h := New(&Config{
+		AppRootDir: tmp,
+		SourceDir:  "src/app",
+		OutputDir:  "deploy",
+		AppPort:    port,
+		ProxyMode:  true,
+		ExitChan:   exit,
+	})
+	h.SetExternalServerMode(true)
+	old := h.current().(*externalStrategy)
+	if _, body := getBody(t, "http://127.0.0.1:"+port+"/v"); body != "v1" {
+		t.Fatalf("expected v1 through proxy, got %q", body)
+	}
+
+	h.SetExternalServerMode(false)
+	defer func() { exit <- true }()
+
+	// An event that read the old strategy before the switch
+	if err := os.WriteFile(mainFile, []byte(fmt.Sprintf(portServer, "v2")), 0644); err != nil {
+		t.Fatalf("writing server file: %v", err)
+	}
+	if err := old.HandleFileEvent("main.go", ".go", mainFile, "write"); err != nil {
+		t.Fatalf("HandleFileEvent: %v", err)
+	}
+	if old.process.Running() {
+		old.Stop()
+		t.Fatal("replaced external strategy started a new binary")
+	}
+	if err := old.Restart(); err != nil {
+		t.Fatalf("Restart: %v", err)
+	}
+	if old.process.Running() {
+		old.Stop()
+		t.Fatal("replaced external strategy restarted")
+	}
+}
+
+// flagPortServer honors -port like the generated server and falls back to a
+// fixed port, binding after a delay.
+const flagPortServer = `package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+func main() {
+	port := flag.String("port", "%s", "")
+	flag.Parse()
+	http.HandleFunc("/v", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "%s")
+	})
+	time.Sleep(%d * time.Millisecond)
+	log.Fatal(http.ListenAndServe(":"+*port, nil))
+}
+`
+
+func TestProxyOnRebuildKeepsPreviousServing(t *testing.T) {
+	tmp := t.TempDir()
+	sourceDir := filepath.Join(tmp, "src", "app")
+	if err := os.MkdirAll(sourceDir, 0755); err != nil {
+		t.Fatalf("creating source directory: %v", err)
+	}
+	port := freePort(t)
+	mainFile := filepath.Join(sourceDir, "main.go")
+	write := func(version string, delay int) {
+		t.Helper()
+		if err := os.WriteFile(mainFile, []byte(fmt.Sprintf(flagPortServer, port, version, delay)), 0644); err != nil {
+			t.Fatalf("writing server file: %v", err)
+		}
+	}
+	write("v1", 0)
+
+	h := New(&Config{
+		AppRootDir:     tmp,
+		SourceDir:      "src/app",
+		OutputDir:      "deploy",
+		AppPort:        port,
+		ProxyOnRebuild: true,
+		ExitChan:       make(chan bool, 1),
+	})
+	h.SetExternalServerMode(true)
+	defer h.current().Stop()
+
+	base := "http://127.0.0.1:" + port
+	if _, body := getBody(t, base+"/v"); body != "v1" {
+		t.Fatalf("expected v1, got %q", body)
+	}
+
+	events, unsubscribe := h.Subscribe()
+	defer unsubscribe()
+	write("v2", 1000)
+	done := make(chan error, 1)
+	go func() { done <- h.RestartServer() }()
+
+	// The new binary is sleeping before it binds, the previous one keeps serving
+	nextEvent(t, events, EventProcessStarted)
+	client := &http.Client{Timeout: time.Second}
+	for end := time.Now().Add(500 * time.Millisecond); time.Now().Before(end); {
+		resp, err := client.Get(base + "/v")
+		if err != nil {
+			t.Fatalf("previous server stopped before the new one was ready: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "v1" {
+			t.Fatalf("expected v1 while v2 starts, got %q", body)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("RestartServer: %v", err)
+	}
+	if _, body := getBody(t, base+"/v"); body != "v2" {
+		t.Fatalf("expected v2 after the handover, got %q", body)
+	}
+	if st := h.Status(); st.Addr != ":"+port {
+		t.Errorf("expected Status on AppPort, got %q", st.Addr)
+	}
+
+	// Later rebuilds run through the proxy
+	write("v3", 0)
+	if err := h.RestartServer(); err != nil {
+		t.Fatalf("RestartServer: %v", err)
+	}
+	if _, body := getBody(t, base+"/v"); body != "v3" {
+		t.Fatalf("expected v3, got %q", body)
+	}
+}
+
+func TestProxyOnRebuildFallsBackForBinariesWithoutPortFlag(t *testing.T) {
+	tmp := t.TempDir()
+	sourceDir := filepath.Join(tmp, "src", "app")
+	if err := os.MkdirAll(sourceDir, 0755); err != nil {
+		t.Fatalf("creating source directory: %v", err)
+	}
+	port := freePort(t)
+	mainFile := filepath.Join(sourceDir, "main.go")
+	// Uses flag without defining -port, so the flag makes it exit with code 2
+	content := strings.Replace(fmt.Sprintf(fixedPortServer, port), "func main() {", "func main() {\n\tflag.Parse()", 1)
+	content = strings.Replace(content, "import (", "import (\n\t\"flag\"", 1)
+	write := func(rev int) {
+		t.Helper()
+		if err := os.WriteFile(mainFile, []byte(fmt.Sprintf("%s\n// rev %d\n", content, rev)), 0644); err != nil {
+			t.Fatalf("writing server file: %v", err)
+		}
+	}
+	write(1)
+
+	h := New(&Config{
+		AppRootDir:     tmp,
+		SourceDir:      "src/app",
+		OutputDir:      "deploy",
+		AppPort:        port,
+		ProxyOnRebuild: true,
+		ExitChan:       make(chan bool, 1),
+	})
+	h.SetExternalServerMode(true)
+	defer h.current().Stop()
+	base := "http://127.0.0.1:" + port
+	getBody(t, base)
+
+	events, unsubscribe := h.Subscribe()
+	defer unsubscribe()
+	starts := func() int {
+		n := 0
+		for len(events) > 0 {
+			if ev := <-events; ev.Type == EventProcessStarted {
+				n++
+			}
+		}
+		return n
+	}
+
+	write(2)
+	if err := h.RestartServer(); err != nil {
+		t.Fatalf("RestartServer: %v", err)
+	}
+	if _, body := getBody(t, base); body != "APP_OK" {
+		t.Fatalf("expected APP_OK after falling back, got %q", body)
+	}
+	if n := starts(); n != 2 {
+		t.Errorf("expected the rejected run and the direct one, got %d starts", n)
+	}
+
+	// No throw-away run once the binary is known to reject -port
+	write(3)
+	if err := h.RestartServer(); err != nil {
+		t.Fatalf("RestartServer: %v", err)
+	}
+	getBody(t, base)
+	if n := starts(); n != 1 {
+		t.Errorf("expected a single start, got %d", n)
+	}
+	if h.current().(*externalStrategy).proxy != nil {
+		t.Error("expected no proxy for a binary without -port")
+	}
+}
+
+func TestExternalShutdownDeadlineClosesProxyConnections(t *testing.T) {
+	entered, unblock := make(chan struct{}), make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-unblock
+	}))
+	defer backend.Close()
+	defer close(unblock)
+	_, backendPort, _ := net.SplitHostPort(backend.Listener.Addr().String())
+
+	port := freePort(t)
+	h := New(&Config{AppRootDir: t.TempDir(), AppPort: port, ProxyMode: true, StopGracePeriod: 10 * time.Second})
+	s := newExternalStrategy(h)
+	if err := s.proxy.Start(); err != nil {
+		t.Fatalf("starting proxy: %v", err)
+	}
+	s.proxy.Release(backendPort)
+
+	events, unsubscribe := h.Subscribe()
+	defer unsubscribe()
+
+	requestErr := make(chan error, 1)
+	go func() {
+		resp, err := http.Get("http://127.0.0.1:" + port + "/slow")
+		if err == nil {
+			_, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		requestErr <- err
+	}()
+	<-entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(ctx); err == nil {
+		t.Fatal("expected the expired deadline to be reported")
+	}
+	select {
+	case err := <-requestErr:
+		if err == nil {
+			t.Fatal("expected the open request to be cut off")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("open request still running after Shutdown returned")
+	}
+	nextEvent(t, events, EventStopped)
+}
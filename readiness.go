@@ -0,0 +1,110 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// readySettle is how long a server on an unknown port ("0") must stay alive to count as ready.
+const readySettle = 300 * time.Millisecond
+
+// ownPortGrace is how long a binary told the port picked for AppPort "0" may
+// not listen on it before it counts as ready on a port of its own choosing.
+const ownPortGrace = 2 * time.Second
+
+// waitReady blocks until the external server process p accepts connections on
+// port (or answers ReadyPath with a non 5xx status). It fails early if the
+// process exits and includes the captured stderr in the error.
+func (s *externalStrategy) waitReady(p *serverProcess, port string) error {
+	timeout := s.handler.ReadyTimeout
+
+	if port == "" || port == "0" {
+		// The binary picks its own port, we can only check that it stays up
+		time.Sleep(min(readySettle, timeout))
+		if exited, err := p.Exited(); exited {
+			return s.notReadyError(p, "exited during startup", err)
+		}
+		return nil
+	}
+
+	addr := net.JoinHostPort("127.0.0.1", port)
+	client := &http.Client{Timeout: time.Second}
+	deadline := time.Now().Add(timeout)
+	// Binaries may ignore the port passed for AppPort "0" and bind their own
+	ownPort := s.proxy == nil && s.handler.AppPort == "0"
+	ownPortDeadline := time.Now().Add(min(ownPortGrace, timeout))
+
+	for {
+		if exited, err := p.Exited(); exited {
+			return s.notReadyError(p, "exited before becoming ready", err)
+		}
+
+		if probeReady(client, addr, s.handler.ReadyPath) {
+			return nil
+		}
+
+		if ownPort && time.Now().After(ownPortDeadline) {
+			s.handler.logEvent(slog.LevelWarn, "Server is not listening on the port it was given, it may use a port of its own",
+				LogKeyPort, port, LogKeyPID, p.PID())
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return s.notReadyError(p, fmt.Sprintf("not ready on port %s after %v", port, timeout), nil)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// probeReady dials addr, or requests path on it when path is set.
+func probeReady(client *http.Client, addr, path string) bool {
+	if path == "" {
+		conn, err := net.DialTimeout("tcp", addr, time.Second)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}
+
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	resp, err := client.Get("http://" + addr + path)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode < http.StatusInternalServerError
+}
+
+// readinessError reports a server that did not become ready, with the exit
+// error (if it exited) and its last stderr lines.
+type readinessError struct {
+	reason  string
+	exitErr error
+	stderr  []string
+}
+
+func (s *externalStrategy) notReadyError(p *serverProcess, reason string, exitErr error) error {
+	return &readinessError{reason: reason, exitErr: exitErr, stderr: s.handler.output.stderrOf(p.Run())}
+}
+
+func (e *readinessError) Error() string {
+	msg := "server " + e.reason
+	if e.exitErr != nil {
+		msg += ": " + e.exitErr.Error()
+	}
+	if len(e.stderr) > 0 {
+		msg += "\nstderr:\n" + strings.Join(e.stderr, "\n")
+	}
+	return msg
+}
+
+func (e *readinessError) Unwrap() error {
+	return e.exitErr
+}
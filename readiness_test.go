@@ -0,0 +1,129 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newReadinessStrategy(t *testing.T, port string, script string) *externalStrategy {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("uses unix shell commands")
+	}
+	h := New(&Config{AppRootDir: t.TempDir(), AppPort: port, ReadyTimeout: 2 * time.Second})
+	s := &externalStrategy{
+		handler: h,
+		port:    port,
+		process: &serverProcess{
+			execPath: "sh",
+			args:     func() []string { return []string{"-c", script} },
+			output: func(run, pid int, stream, line string) {
+				h.output.add(OutputLine{Stream: stream, RunID: run, PID: pid, Text: line})
+			},
+			nextRun: h.output.newRun,
+		},
+	}
+	if err := s.process.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	t.Cleanup(func() { s.process.Stop(time.Second) })
+	return s
+}
+
+func TestWaitReadyReportsStderrWhenProcessDies(t *testing.T) {
+	s := newReadinessStrategy(t, "1", "echo 'bind: permission denied' >&2; exit 3")
+
+	err := s.waitReady(s.process, s.port)
+	if err == nil {
+		t.Fatal("expected readiness error")
+	}
+	if !strings.Contains(err.Error(), "bind: permission denied") {
+		t.Errorf("expected captured stderr in error, got: %v", err)
+	}
+}
+
+func TestWaitReadyTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	s := newReadinessStrategy(t, port, "exec sleep 30")
+	if err := s.waitReady(s.process, s.port); err != nil {
+		t.Fatalf("expected server to be ready: %v", err)
+	}
+}
+
+func TestWaitReadyHTTPPath(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" || calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(ts.URL, "http://"))
+
+	s := newReadinessStrategy(t, port, "exec sleep 30")
+	s.handler.ReadyPath = "/health"
+	if err := s.waitReady(s.process, s.port); err != nil {
+		t.Fatalf("expected server to be ready: %v", err)
+	}
+	if calls.Load() < 3 {
+		t.Errorf("expected polling until /health succeeds, got %d calls", calls.Load())
+	}
+}
+
+func TestWaitReadyTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	ln.Close() // nothing listens on port
+
+	s := newReadinessStrategy(t, port, "exec sleep 30")
+	s.handler.ReadyTimeout = 300 * time.Millisecond
+	if err := s.waitReady(s.process, s.port); err == nil || !strings.Contains(err.Error(), "not ready") {
+		t.Fatalf("expected timeout error, got: %v", err)
+	}
+}
+
+func TestExternalStartFailsWhenPortIsTaken(t *testing.T) {
+	tmp := t.TempDir()
+	sourceDir := filepath.Join(tmp, "src", "app")
+	if err := os.MkdirAll(sourceDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	port := freePort(t)
+	main := fmt.Sprintf("package main\n\nimport \"net/http\"\n\nfunc main() { panic(http.ListenAndServe(\":%s\", nil)) }\n", port)
+	if err := os.WriteFile(filepath.Join(sourceDir, "main.go"), []byte(main), 0644); err != nil {
+		t.Fatal(err)
+	}
+	occupyPort(t, port)
+
+	h := New(&Config{AppRootDir: tmp, SourceDir: "src/app", OutputDir: "deploy", AppPort: port, CrashRestartLimit: -1})
+	s := newExternalStrategy(h)
+	defer s.Stop()
+
+	// The foreign listener must not be taken for the binary being ready
+	if err := s.Start(nil); err == nil || !strings.Contains(err.Error(), "in use") {
+		t.Fatalf("expected port in use error, got: %v", err)
+	}
+	if s.process.Running() {
+		t.Error("expected the binary not to be started")
+	}
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"slices"
+)
+
+// StrategyFactory creates a strategy for h, e.g. a runner for a prebuilt
+// binary or a remote stand-in. It is called on every switch to the strategy.
+type StrategyFactory func(h *ServerHandler) ServerStrategy
+
+// registerBuiltinStrategies adds the In-Memory and External strategies under
+// ModeInMemory and ModeExternal.
+func (h *ServerHandler) registerBuiltinStrategies() {
+	h.strategies = map[string]StrategyFactory{
+		ModeInMemory: func(h *ServerHandler) ServerStrategy { return newInMemoryStrategy(h) },
+		ModeExternal: func(h *ServerHandler) ServerStrategy { return newExternalStrategy(h) },
+	}
+}
+
+// RegisterStrategy makes a custom strategy available to SetStrategy under
+// name. The built-in names ModeInMemory and ModeExternal cannot be replaced.
+func (h *ServerHandler) RegisterStrategy(name string, factory StrategyFactory) error {
+	if name == "" || factory == nil {
+		return errors.New("strategy name and factory are required")
+	}
+	if name == ModeInMemory || name == ModeExternal {
+		return errors.New("strategy " + name + " is built in")
+	}
+	h.mu.Lock()
+	h.strategies[name] = factory
+	h.mu.Unlock()
+	return nil
+}
+
+// SetStrategy stops the current strategy and starts the one registered under
+// name in its own goroutine, as Start may block until ExitChan. File events,
+// reloads and logging keep going through the handler.
+func (h *ServerHandler) SetStrategy(name string) error {
+	h.mu.RLock()
+	current, mode := h.strategy, h.mode
+	_, ok := h.strategies[name]
+	h.mu.RUnlock()
+	if name == mode {
+		return nil
+	}
+	if !ok {
+		return errors.New("unknown server strategy: " + name)
+	}
+
+	h.logEvent(slog.LevelInfo, "Switching to "+name+" strategy...", LogKeyMode, name)
+	if err := current.Stop(); err != nil {
+		return errors.Join(errors.New("failed to stop "+mode+" strategy"), err)
+	}
+	h.useStrategy(name)
+	go h.StartServer(nil)
+	return nil
+}
+
+// Strategies returns the names SetStrategy accepts.
+func (h *ServerHandler) Strategies() []string {
+	h.mu.RLock()
+	names := make([]string, 0, len(h.strategies))
+	for name := range h.strategies {
+		names = append(names, name)
+	}
+	h.mu.RUnlock()
+	slices.Sort(names)
+	return names
+}
+
+// useStrategy makes a new instance of the strategy registered under name the
+// current one and returns it, without starting it.
+func (h *ServerHandler) useStrategy(name string) ServerStrategy {
+	h.mu.RLock()
+	factory := h.strategies[name]
+	h.mu.RUnlock()
+	s := factory(h)
+
+	h.mu.Lock()
+	h.strategy, h.mode = s, name
+	h.mu.Unlock()
+	h.modeChanged(name)
+	return s
+}
+
+// current returns the active strategy. Mode switches may replace it from
+// other goroutines (e.g. a debounced rebuild), so it is read under h.mu.
+func (h *ServerHandler) current() ServerStrategy {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.strategy
+}
+
+// currentMode returns the name the active strategy is registered under.
+func (h *ServerHandler) currentMode() string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.mode
+}
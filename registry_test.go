@@ -0,0 +1,180 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeStrategy records the calls the handler routes to it.
+type fakeStrategy struct {
+	mu      sync.Mutex
+	started chan struct{}
+	events  []string
+	stopped bool
+}
+
+func newFakeStrategy() *fakeStrategy {
+	return &fakeStrategy{started: make(chan struct{})}
+}
+
+func (f *fakeStrategy) Start(wg *sync.WaitGroup) error {
+	close(f.started)
+	if wg != nil {
+		wg.Done()
+	}
+	return nil
+}
+
+func (f *fakeStrategy) Stop() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stopped = true
+	return nil
+}
+
+func (f *fakeStrategy) Run(ctx context.Context) error      { return f.Start(nil) }
+func (f *fakeStrategy) Shutdown(ctx context.Context) error { return f.Stop() }
+func (f *fakeStrategy) Restart() error                     { return nil }
+func (f *fakeStrategy) Name() string                       { return "Fake" }
+func (f *fakeStrategy) HandleFileEvent(name, ext, path, event string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = append(f.events, event+" "+path)
+	return nil
+}
+
+func TestRegisterStrategyValidation(t *testing.T) {
+	h := New(nil)
+	factory := func(h *ServerHandler) ServerStrategy { return newFakeStrategy() }
+
+	if err := h.RegisterStrategy(ModeExternal, factory); err == nil {
+		t.Error("expected error replacing a built-in strategy")
+	}
+	if err := h.RegisterStrategy("", factory); err == nil {
+		t.Error("expected error for an empty name")
+	}
+	if err := h.SetStrategy("missing"); err == nil {
+		t.Error("expected error for an unregistered strategy")
+	}
+	if err := h.RegisterStrategy("fake", factory); err != nil {
+		t.Fatal(err)
+	}
+	names := h.Strategies()
+	if len(names) != 3 || names[0] != ModeExternal || names[1] != "fake" || names[2] != ModeInMemory {
+		t.Fatalf("unexpected strategies %v", names)
+	}
+}
+
+func TestSetStrategyRoutesToCustomStrategy(t *testing.T) {
+	exit := make(chan bool, 1)
+	h := New(&Config{AppPort: freePort(t), ExitChan: exit})
+	defer func() { exit <- true }()
+
+	fake := newFakeStrategy()
+	if err := h.RegisterStrategy("fake", func(*ServerHandler) ServerStrategy { return fake }); err != nil {
+		t.Fatal(err)
+	}
+	events, cancel := h.Subscribe()
+	defer cancel()
+
+	store := memStore{}
+	ui := NewServerModeHandler(h, store, nil)
+	if err := ui.SetStrategy("fake"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-fake.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("custom strategy was not started")
+	}
+	if ev := nextEvent(t, events, EventModeChanged); ev.Mode != "fake" {
+		t.Fatalf("expected mode fake, got %q", ev.Mode)
+	}
+	if st := h.Status(); st.Mode != "fake" || st.Strategy != "Fake" {
+		t.Fatalf("unexpected status %+v", st)
+	}
+	if label := ui.Label(); label != "SERVER: FAKE" {
+		t.Fatalf("unexpected label %q", label)
+	}
+	if store[StoreKeyServerStrategy] != "fake" || store[StoreKeyExternalServer] != "true" {
+		t.Fatalf("strategy not stored: %v", store)
+	}
+
+	h.NewFileEvent("main.go", ".go", "web/main.go", "write")
+	fake.mu.Lock()
+	routed := len(fake.events) == 1 && fake.events[0] == "write web/main.go"
+	fake.mu.Unlock()
+	if !routed {
+		t.Fatalf("file event not routed to the custom strategy: %v", fake.events)
+	}
+
+	// Execute leaves the custom strategy for In-Memory
+	ui.Execute()
+	if h.Status().Mode != ModeInMemory || !fake.stopped {
+		t.Fatalf("expected in-memory after Execute, got %q", h.Status().Mode)
+	}
+	if store[StoreKeyServerStrategy] != ModeInMemory {
+		t.Fatalf("mode not stored: %v", store)
+	}
+}
+
+func TestRestoreStoredStrategy(t *testing.T) {
+	exit := make(chan bool, 1)
+	h := New(&Config{AppPort: freePort(t), ExitChan: exit})
+	defer func() { exit <- true }()
+
+	fake := newFakeStrategy()
+	h.RegisterStrategy("fake", func(*ServerHandler) ServerStrategy { return fake })
+
+	ui := NewServerModeHandler(h, memStore{StoreKeyServerStrategy: "fake"}, nil)
+	if err := ui.Restore(); err != nil {
+		t.Fatal(err)
+	}
+	if h.Status().Mode != "fake" {
+		t.Fatalf("expected stored strategy, got %q", h.Status().Mode)
+	}
+
+	unknown := NewServerModeHandler(New(nil), memStore{StoreKeyServerStrategy: "gone"}, nil)
+	if err := unknown.Restore(); err == nil {
+		t.Fatal("expected error restoring an unregistered strategy")
+	}
+}
+
+func TestCreateTemplateServerFromCustomStrategy(t *testing.T) {
+	tmp := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmp, "go.mod"), []byte("module gen\n\ngo 1.22\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(tmp, "web", "public"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	h := New(&Config{AppRootDir: tmp, SourceDir: "web", OutputDir: "web", PublicDir: "web/public", AppPort: freePort(t), ExitChan: make(chan bool, 1)})
+
+	fake := newFakeStrategy()
+	if err := h.RegisterStrategy("fake", func(*ServerHandler) ServerStrategy { return fake }); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.SetStrategy("fake"); err != nil {
+		t.Fatal(err)
+	}
+	<-fake.started
+
+	progress := make(chan string, 16)
+	if err := h.CreateTemplateServer(progress); err != nil {
+		t.Fatalf("CreateTemplateServer: %v", err)
+	}
+	defer h.current().Stop()
+	close(progress)
+	for msg := range progress {
+		if msg == "Server is already in external mode." {
+			t.Fatal("custom strategy taken for external mode")
+		}
+	}
+	if h.Status().Mode != ModeExternal || !fake.stopped {
+		t.Fatalf("expected external mode with the custom strategy stopped, got %q", h.Status().Mode)
+	}
+}
@@ -0,0 +1,229 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// reloadExtensions are the asset files that trigger a browser reload when LiveReload is on.
+var reloadExtensions = []string{".html", ".css", ".js", ".wasm"}
+
+// isReloadAsset reports whether a file event extension (with or without dot) is a reload asset.
+func isReloadAsset(extension string) bool {
+	extension = "." + strings.TrimPrefix(extension, ".")
+	for _, ext := range reloadExtensions {
+		if ext == extension {
+			return true
+		}
+	}
+	return false
+}
+
+// reloadHub keeps the browsers connected to the live-reload SSE endpoint.
+type reloadHub struct {
+	mu      sync.Mutex
+	clients map[chan struct{}]struct{}
+	closing chan struct{} // closed by closeAll to end open streams
+}
+
+func newReloadHub() *reloadHub {
+	return &reloadHub{
+		clients: make(map[chan struct{}]struct{}),
+		closing: make(chan struct{}),
+	}
+}
+
+// Broadcast asks every connected browser to reload.
+func (r *reloadHub) Broadcast() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for c := range r.clients {
+		select {
+		case c <- struct{}{}:
+		default: // a reload is already pending for this client
+		}
+	}
+}
+
+// closeAll ends open streams so http.Server.Shutdown does not wait on them.
+// Browsers reconnect and reload once the server is back.
+func (r *reloadHub) closeAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	close(r.closing)
+	r.closing = make(chan struct{})
+}
+
+func (r *reloadHub) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	c := make(chan struct{}, 1)
+	r.mu.Lock()
+	r.clients[c] = struct{}{}
+	closing := r.closing
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		delete(r.clients, c)
+		r.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	for {
+		select {
+		case <-c:
+			fmt.Fprint(w, "event: reload\ndata: reload\n\n")
+			flusher.Flush()
+		case <-closing:
+			return
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
+
+// reloadScript reloads the page on a reload event, or when the stream
+// reconnects after the server went away (e.g. in-memory restart).
+func (h *ServerHandler) reloadScript() string {
+	return `<script>(function(){var lost=false,es=new EventSource("` + h.ReloadPath + `");` +
+		`es.addEventListener("reload",function(){location.reload()});` +
+		`es.onerror=function(){lost=true};` +
+		`es.onopen=function(){if(lost){location.reload()}}})();</script>`
+}
+
+// Reload tells every browser connected to the live-reload endpoint to reload.
+func (h *ServerHandler) Reload() {
+	h.reload.Broadcast()
+}
+
+// liveReload serves the SSE endpoint and injects the reload script when
+// Config.LiveReload is set, otherwise it returns next unchanged.
+func (h *ServerHandler) liveReload(next http.Handler) http.Handler {
+	if !h.LiveReload {
+		return next
+	}
+	inject := h.ReloadScriptMiddleware(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == h.ReloadPath {
+			h.reload.ServeHTTP(w, r)
+			return
+		}
+		inject.ServeHTTP(w, r)
+	})
+}
+
+// ReloadScriptMiddleware adds the live-reload client script to HTML responses.
+// Use it on custom handlers; with Config.LiveReload it is applied automatically.
+func (h *ServerHandler) ReloadScriptMiddleware(next http.Handler) http.Handler {
+	script := []byte(h.reloadScript())
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || !strings.Contains(r.Header.Get("Accept"), "text/html") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// Ask for an uncompressed body so the script can be inserted
+		r.Header.Del("Accept-Encoding")
+
+		iw := &injectWriter{ResponseWriter: w, script: script, status: http.StatusOK}
+		next.ServeHTTP(iw, r)
+		iw.finish()
+	})
+}
+
+// injectWriter buffers HTML responses to insert script before </body>.
+// Any other response is passed through untouched.
+type injectWriter struct {
+	http.ResponseWriter
+	script  []byte
+	status  int
+	decided bool // html has been determined
+	html    bool
+	wrote   bool // WriteHeader was forwarded
+	buf     bytes.Buffer
+}
+
+func (w *injectWriter) WriteHeader(code int) {
+	if w.decided || w.wrote {
+		return
+	}
+	w.status = code
+	if ct := w.Header().Get("Content-Type"); ct != "" {
+		w.decide(ct)
+	}
+}
+
+func (w *injectWriter) decide(contentType string) {
+	w.decided = true
+	hasBody := w.status >= http.StatusOK && w.status != http.StatusNoContent && w.status != http.StatusNotModified
+	w.html = hasBody && strings.HasPrefix(contentType, "text/html") && w.Header().Get("Content-Encoding") == ""
+	if !w.html {
+		w.wrote = true
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+}
+
+func (w *injectWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		ct := w.Header().Get("Content-Type")
+		if ct == "" {
+			ct = http.DetectContentType(b)
+			w.Header().Set("Content-Type", ct)
+		}
+		w.decide(ct)
+	}
+	if w.html {
+		return w.buf.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *injectWriter) Flush() {
+	if w.decided && !w.html {
+		if f, ok := w.ResponseWriter.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+}
+
+func (w *injectWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// finish writes the buffered HTML with the script inserted.
+func (w *injectWriter) finish() {
+	if !w.decided {
+		// handler wrote nothing
+		w.ResponseWriter.WriteHeader(w.status)
+		return
+	}
+	if !w.html {
+		return
+	}
+
+	body := w.buf.Bytes()
+	i := bytes.LastIndex(bytes.ToLower(body), []byte("</body>"))
+	if i < 0 {
+		i = len(body)
+	}
+	out := make([]byte, 0, len(body)+len(w.script))
+	out = append(out, body[:i]...)
+	out = append(out, w.script...)
+	out = append(out, body[i:]...)
+
+	w.Header().Set("Content-Length", strconv.Itoa(len(out)))
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.Write(out)
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReloadScriptInjectedIntoHTML(t *testing.T) {
+	h := New(&Config{AppRootDir: t.TempDir(), LiveReload: true})
+	page := h.liveReload(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html><body><p>hi</p></body></html>")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	rec := httptest.NewRecorder()
+	page.ServeHTTP(rec, req)
+
+	body := rec.Body.String()
+	if !strings.Contains(body, `new EventSource("/__tinywasm/reload")`) {
+		t.Fatalf("expected reload script, got %q", body)
+	}
+	if !strings.HasSuffix(body, "</script></body></html>") {
+		t.Errorf("expected script before </body>, got %q", body)
+	}
+	if rec.Header().Get("Content-Length") != fmt.Sprint(len(body)) {
+		t.Errorf("Content-Length %q does not match body length %d", rec.Header().Get("Content-Length"), len(body))
+	}
+
+	// Non HTML responses are untouched
+	js := h.liveReload(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/javascript")
+		fmt.Fprint(w, "console.log(1)")
+	}))
+	rec = httptest.NewRecorder()
+	js.ServeHTTP(rec, req)
+	if rec.Body.String() != "console.log(1)" {
+		t.Errorf("expected untouched body, got %q", rec.Body.String())
+	}
+}
+
+// readReloadEvents connects to the SSE endpoint and forwards received event names.
+func readReloadEvents(t *testing.T, url string) <-chan string {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("connecting to reload endpoint: %v", err)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected event stream, got %q", ct)
+	}
+	events := make(chan string, 10)
+	go func() {
+		defer resp.Body.Close()
+		sc := bufio.NewScanner(resp.Body)
+		for sc.Scan() {
+			if name, ok := strings.CutPrefix(sc.Text(), "event: "); ok {
+				events <- name
+			}
+		}
+		close(events)
+	}()
+	t.Cleanup(func() { resp.Body.Close() })
+	return events
+}
+
+func expectReload(t *testing.T, events <-chan string, reason string) {
+	t.Helper()
+	select {
+	case name := <-events:
+		if name != "reload" {
+			t.Fatalf("%s: expected reload event, got %q", reason, name)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("%s: no reload event received", reason)
+	}
+}
+
+func TestLiveReloadEventsInMemory(t *testing.T) {
+	port := freePort(t)
+	cfg := &Config{
+		AppRootDir: t.TempDir(),
+		AppPort:    port,
+		LiveReload: true,
+		Routes:     []func(*http.ServeMux){textRoute("/v", "v1")},
+		ExitChan:   make(chan bool, 1),
+	}
+	h := New(cfg)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go h.StartServer(&wg)
+	defer func() {
+		cfg.ExitChan <- true
+		wg.Wait()
+	}()
+
+	base := "http://127.0.0.1:" + port
+	getBody(t, base+"/v")
+	events := readReloadEvents(t, base+cfg.ReloadPath)
+
+	if err := h.ReplaceRoutes([]func(*http.ServeMux){textRoute("/v", "v2")}); err != nil {
+		t.Fatalf("ReplaceRoutes: %v", err)
+	}
+	expectReload(t, events, "route swap")
+
+	if err := h.NewFileEvent("style.css", ".css", "/app/web/public/style.css", "write"); err != nil {
+		t.Fatalf("NewFileEvent: %v", err)
+	}
+	expectReload(t, events, "asset event")
+
+	if exts := h.SupportedExtensions(); !strings.Contains(strings.Join(exts, " "), ".css") {
+		t.Errorf("expected asset extensions to be observed, got %v", exts)
+	}
+
+	// The stream must not keep Stop waiting for the shutdown timeout
+	start := time.Now()
+	if err := h.RestartServer(); err != nil {
+		t.Fatalf("RestartServer: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("restart waited %v on open reload streams", elapsed)
+	}
+	if _, ok := <-events; ok {
+		t.Error("expected reload stream to be closed by restart")
+	}
+}
+
+func TestLiveReloadWithoutProxyModeWarns(t *testing.T) {
+	var logs []string
+	h := New(&Config{AppRootDir: t.TempDir(), LiveReload: true})
+	h.SetLog(func(messages ...any) { logs = append(logs, plainMessage(messages...)) })
+
+	h.useStrategy(ModeExternal)
+	if len(logs) != 1 || !strings.Contains(logs[0], "LiveReload needs ProxyMode") {
+		t.Fatalf("expected a warning about LiveReload without ProxyMode, got %q", logs)
+	}
+
+	logs = nil
+	h.ProxyMode = true
+	h.useStrategy(ModeExternal)
+	for _, line := range logs {
+		if strings.Contains(line, "LiveReload") {
+			t.Errorf("unexpected warning in ProxyMode: %q", line)
+		}
+	}
+}
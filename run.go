@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"time"
+)
+
+// Run starts the server with the current strategy and blocks until ctx is
+// cancelled, then shuts it down within StopGracePeriod. Mode switches while
+// running are followed: the strategy active when ctx ends is the one shut down.
+// Unlike StartServer it does not depend on Config.ExitChan.
+func (h *ServerHandler) Run(ctx context.Context) error {
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+	h.runMu.Lock()
+	h.stopRun = stop
+	h.runMu.Unlock()
+
+	s := h.current()
+	if err := s.Run(ctx); err != nil {
+		return err
+	}
+	// The strategy also returns when a mode switch replaced it
+	<-ctx.Done()
+	if h.current() == s {
+		return nil // already shut down by its Run or by Shutdown
+	}
+	sctx, cancel := h.shutdownContext(ctx)
+	defer cancel()
+	return h.current().Shutdown(sctx)
+}
+
+// Shutdown stops the server and makes Run return. ctx bounds the graceful
+// part: open HTTP connections and the time the external binary gets to exit
+// before it is killed. A compile in progress is cancelled.
+func (h *ServerHandler) Shutdown(ctx context.Context) error {
+	err := h.current().Shutdown(ctx)
+
+	h.runMu.Lock()
+	if h.stopRun != nil {
+		h.stopRun()
+		h.stopRun = nil
+	}
+	h.runMu.Unlock()
+	return err
+}
+
+// shutdownContext gives a shutdown triggered by the end of ctx StopGracePeriod
+// to complete, keeping the values of ctx.
+func (h *ServerHandler) shutdownContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), h.StopGracePeriod)
+}
+
+// gracePeriod is the time left until the ctx deadline, fallback without one.
+func gracePeriod(ctx context.Context, fallback time.Duration) time.Duration {
+	if deadline, ok := ctx.Deadline(); ok {
+		return max(time.Until(deadline), 0)
+	}
+	return fallback
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// runAsync calls h.Run and returns the channel receiving its result.
+func runAsync(h *ServerHandler, ctx context.Context) <-chan error {
+	done := make(chan error, 1)
+	go func() { done <- h.Run(ctx) }()
+	return done
+}
+
+func waitRun(t *testing.T, done <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(10 * time.Second):
+		t.Fatal("Run did not return")
+		return nil
+	}
+}
+
+func TestRunInMemoryStopsOnCancel(t *testing.T) {
+	port := freePort(t)
+	h := New(&Config{
+		AppPort: port,
+		Routes:  []func(*http.ServeMux){textRoute("/v", "v1")},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := runAsync(h, ctx)
+	if _, body := getBody(t, "http://127.0.0.1:"+port+"/v"); body != "v1" {
+		t.Fatalf("expected v1, got %q", body)
+	}
+
+	cancel()
+	if err := waitRun(t, done); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if err := waitPortFree(port, time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunReturnsBindError(t *testing.T) {
+	port := freePort(t)
+	occupyPort(t, port)
+
+	h := New(&Config{AppPort: port})
+	if err := waitRun(t, runAsync(h, context.Background())); err == nil {
+		t.Fatal("expected bind error from Run")
+	}
+}
+
+func TestShutdownDeadlineFromContext(t *testing.T) {
+	port := freePort(t)
+	release := make(chan struct{})
+	defer close(release)
+	slow := func(mux *http.ServeMux) {
+		mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		})
+	}
+	h := New(&Config{AppPort: port, Routes: []func(*http.ServeMux){slow}})
+	done := runAsync(h, context.Background())
+	defer func() { h.Shutdown(context.Background()); waitRun(t, done) }()
+
+	getBody(t, "http://127.0.0.1:"+port+"/missing")
+	slowErr := make(chan error, 1)
+	go func() {
+		resp, err := http.Get("http://127.0.0.1:" + port + "/slow")
+		if err == nil {
+			resp.Body.Close()
+		}
+		slowErr <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := h.Shutdown(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if took := time.Since(start); took > 2*time.Second {
+		t.Fatalf("Shutdown ignored the context deadline, took %v", took)
+	}
+	// The deadline ends the connections still open
+	select {
+	case err := <-slowErr:
+		if err == nil {
+			t.Error("expected the open request to be cut off")
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("open connection outlived the Shutdown deadline")
+	}
+}
+
+func TestRunExternalStopsProcessOnCancel(t *testing.T) {
+	tmp := t.TempDir()
+	sourceDir := filepath.Join(tmp, "src", "app")
+	if err := os.MkdirAll(filepath.Join(tmp, "deploy", "public"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(sourceDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	port := freePort(t)
+	if err := os.WriteFile(filepath.Join(sourceDir, "main.go"), []byte(fmt.Sprintf(crashingServer, port)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	h := New(&Config{AppRootDir: tmp, SourceDir: "src/app", OutputDir: "deploy", AppPort: port})
+	h.useStrategy(ModeExternal)
+	events, unsubscribe := h.Subscribe()
+	defer unsubscribe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := runAsync(h, ctx)
+	if _, body := getBody(t, "http://127.0.0.1:"+port); body != "APP_OK" {
+		t.Fatalf("expected APP_OK, got %q", body)
+	}
+	waitStatus(t, h, func(st BuildStatus) bool { return st.Running })
+
+	cancel()
+	if err := waitRun(t, done); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if h.Status().PID != 0 {
+		t.Fatal("external server still running after cancel")
+	}
+	stopped := 0
+	for len(events) > 0 {
+		if ev := <-events; ev.Type == EventStopped {
+			stopped++
+		}
+	}
+	if stopped != 1 {
+		t.Errorf("expected one Stopped event, got %d", stopped)
+	}
+}
@@ -1,43 +1,92 @@
 package server
 
 import (
+	"context"
+	"log/slog"
 	"net/http"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type ServerHandler struct {
 	*Config
-	mainFileExternalServer string // eg: main.server.go
+	mainFileExternalServer string       // eg: main.server.go
+	mu                     sync.RWMutex // guards strategy, mode and strategies, see current
 	strategy               ServerStrategy
-	inMemory               bool // true if running internal server, false if external process
+	mode                   string // name the current strategy is registered under, see SetStrategy
+	strategies             map[string]StrategyFactory
 	buildOnDisk            bool // true if compilation artifacts should be written to disk
 	log                    func(message ...any)
+	slog                   *slog.Logger  // set by SetSlogHandler
+	reload                 *reloadHub    // live-reload SSE clients
+	mainInputRemoved       atomic.Bool   // external mode fell back to in-memory because the main input file was deleted
+	output                 *outputBuffer // recent lines written by the external server
+	events                 *eventHub     // lifecycle event subscribers
+	runMu                  sync.Mutex
+	stopRun                context.CancelFunc // ends the current Run, see Shutdown
+	wasmExecOnce           sync.Once
+	wasmExecPath           string // wasm_exec.js of the toolchain, see wasmExecFile
+	wasmExecErr            error
+	portMu                 sync.Mutex
+	port                   string     // port actually bound for AppPort, see Port
+	routesMu               sync.Mutex // guards Config.Routes against ReplaceRoutes, see routes
 }
 
 type Config struct {
-	AppRootDir                  string                 // e.g., /home/user/project (application root directory)
-	SourceDir                   string                 // directory location of main.go e.g., src/cmd/appserver (relative to AppRootDir)
-	OutputDir                   string                 // compilation and execution directory e.g., deploy/appserver (relative to AppRootDir)
-	PublicDir                   string                 // default public dir for generated server (e.g., src/web/public)
-	MainInputFile               string                 // main input file name (default: "main.go", can be "server.go", etc.)
-	ArgumentsForCompilingServer func() []string        // e.g., []string{"-X 'main.version=v1.0.0'"}
-	ArgumentsToRunServer        func() []string        // e.g., []string{"dev"}
-	AppPort                     string                 // e.g., 8080
-	Routes                      []func(*http.ServeMux) // Functions to register routes on the HTTP server
-	ExitChan                    chan bool              // Global channel to signal shutdown
+	AppRootDir                  string                            // e.g., /home/user/project (application root directory)
+	SourceDir                   string                            // directory location of main.go e.g., src/cmd/appserver (relative to AppRootDir)
+	OutputDir                   string                            // compilation and execution directory e.g., deploy/appserver (relative to AppRootDir)
+	PublicDir                   string                            // default public dir for generated server (e.g., src/web/public)
+	MainInputFile               string                            // main input file name (default: "main.go", can be "server.go", etc.)
+	ArgumentsForCompilingServer func() []string                   // e.g., []string{"-X 'main.version=v1.0.0'"}
+	ArgumentsToRunServer        func() []string                   // e.g., []string{"dev"}
+	AppPort                     string                            // e.g., 8080
+	Routes                      []func(*http.ServeMux)            // Functions to register routes on the HTTP server
+	Middleware                  []func(http.Handler) http.Handler // in-memory mode: wrap the routes in order, the first is the outermost e.g., h.Recover, h.LogRequests, CORS(), NoCache
+	ExitChan                    chan bool                         // Global channel to signal shutdown
+	StopGracePeriod             time.Duration                     // time the external server gets to exit after SIGTERM before SIGKILL, and open in-memory connections on Stop (default: 5s)
+	ReadyPath                   string                            // optional HTTP path polled to detect the external server is ready e.g., /health (default: TCP connect on AppPort)
+	ReadyTimeout                time.Duration                     // max time the external server has to become ready (default: 10s)
+	ProxyMode                   bool                              // external mode: the handler owns AppPort and reverse-proxies to the binary, which runs on an internal port passed as -port and PORT
+	ProxyOnRebuild              bool                              // external mode without ProxyMode: the first rebuild starts the binary on an internal port passed as -port and PORT, then switches to ProxyMode, so the previous build keeps serving until the new one is ready
+	EditorURL                   string                            // link format for file:line in the compile error page (default: vscode://file/{file}:{line}:{col})
+	LiveReload                  bool                              // serve the ReloadPath SSE endpoint and inject the reload script into HTML (in-memory and ProxyMode)
+	ReloadPath                  string                            // live-reload SSE endpoint (default: /__tinywasm/reload)
+	DebounceDelay               time.Duration                     // external mode: merge file events within this window into one rebuild (default: 0, rebuild on every event)
+	CrashRestartDelay           time.Duration                     // wait before restarting an external server that exited on its own, doubled on each consecutive crash (default: 500ms)
+	CrashRestartLimit           int                               // consecutive crash restarts before giving up and reporting a crash loop (default: 5, negative: never restart)
+	OutputBufferLines           int                               // lines of external server stdout/stderr kept for Output (default: 1000)
+	WasmExecPath                string                            // URL path the in-memory server serves wasm_exec.js of the Go toolchain on (default: /wasm_exec.js)
+	CopyWasmExec                bool                              // CreateTemplateServer copies that wasm_exec.js into PublicDir at WasmExecPath
+	SPAFallback                 bool                              // serve PublicDir/index.html for unknown GET paths without a file extension, for client-side routers (in-memory and generated server)
+	SPAExcludePrefixes          []string                          // path prefixes that never fall back to index.html e.g., []string{"/api/"}
+	CrossOriginIsolation        bool                              // send COOP/COEP headers with static files so pages can use SharedArrayBuffer (in-memory and generated server)
+	PortPolicy                  PortPolicy                        // what to do when AppPort is busy: PortStrict (default, fail), PortNext or PortAny. Other than strict, the external binary gets the port as -port and PORT
 }
 
 // NewConfig provides a default configuration.
 func NewConfig() *Config {
 	return &Config{
-		AppRootDir:    ".",
-		SourceDir:     "web",
-		OutputDir:     "web",
-		PublicDir:     "web/public",
-		MainInputFile: "main.go", // Default convention
-		AppPort:       "8080",
-		Routes:        nil,
-		ExitChan:      make(chan bool),
+		AppRootDir:        ".",
+		SourceDir:         "web",
+		OutputDir:         "web",
+		PublicDir:         "web/public",
+		MainInputFile:     "main.go", // Default convention
+		AppPort:           "8080",
+		Routes:            nil,
+		ExitChan:          make(chan bool),
+		StopGracePeriod:   5 * time.Second,
+		ReadyTimeout:      10 * time.Second,
+		EditorURL:         "vscode://file/{file}:{line}:{col}",
+		ReloadPath:        "/__tinywasm/reload",
+		CrashRestartDelay: 500 * time.Millisecond,
+		CrashRestartLimit: 5,
+		OutputBufferLines: 1000,
+		WasmExecPath:      "/wasm_exec.js",
 	}
 }
 
@@ -74,6 +123,32 @@ func New(c *Config) *ServerHandler {
 		if c.ArgumentsForCompilingServer == nil {
 			c.ArgumentsForCompilingServer = func() []string { return nil }
 		}
+		if c.StopGracePeriod == 0 {
+			c.StopGracePeriod = dc.StopGracePeriod
+		}
+		if c.ReadyTimeout == 0 {
+			c.ReadyTimeout = dc.ReadyTimeout
+		}
+		if c.EditorURL == "" {
+			c.EditorURL = dc.EditorURL
+		}
+		if c.ReloadPath == "" {
+			c.ReloadPath = dc.ReloadPath
+		}
+		if c.CrashRestartDelay == 0 {
+			c.CrashRestartDelay = dc.CrashRestartDelay
+		}
+		if c.CrashRestartLimit == 0 {
+			c.CrashRestartLimit = dc.CrashRestartLimit
+		}
+		if c.OutputBufferLines == 0 {
+			c.OutputBufferLines = dc.OutputBufferLines
+		}
+		if c.WasmExecPath == "" {
+			c.WasmExecPath = dc.WasmExecPath
+		} else if !strings.HasPrefix(c.WasmExecPath, "/") {
+			c.WasmExecPath = "/" + c.WasmExecPath
+		}
 		if c.ArgumentsToRunServer == nil {
 			c.ArgumentsToRunServer = func() []string { return nil }
 		}
@@ -82,10 +157,14 @@ func New(c *Config) *ServerHandler {
 	sh := &ServerHandler{
 		Config:                 c,
 		mainFileExternalServer: c.MainInputFile, // Use configured file name
+		reload:                 newReloadHub(),
+		output:                 newOutputBuffer(c.OutputBufferLines),
+		events:                 newEventHub(),
 	}
 
 	// Default to In-Memory Strategy (Internal Server)
-	sh.inMemory = true
+	sh.registerBuiltinStrategies()
+	sh.mode = ModeInMemory
 	sh.strategy = newInMemoryStrategy(sh)
 	// sh.Logger("Server initialized in In-Memory Mode (default)")
 
@@ -100,10 +179,15 @@ func (h *ServerHandler) SetLog(f func(message ...any)) {
 	h.log = f
 }
 
+// Logger writes a plain message to the SetLog function and, as an Info
+// record, to the SetSlogHandler handler.
 func (h *ServerHandler) Logger(messages ...any) {
 	if h.log != nil {
 		h.log(messages...)
 	}
+	if h.slog != nil {
+		h.slog.Info(plainMessage(messages...))
+	}
 }
 
 // MainInputFileRelativePath returns the path relative to AppRootDir (e.g., "src/cmd/appserver/main.go")
@@ -111,16 +195,28 @@ func (h *ServerHandler) MainInputFileRelativePath() string {
 	return filepath.Join(h.SourceDir, h.mainFileExternalServer)
 }
 
+// SupportedExtensions returns the file extensions to watch: Go files, the
+// extensions of the files the external server embeds (known once its first
+// build started) and, with LiveReload, the browser assets.
 func (h *ServerHandler) SupportedExtensions() []string {
-	return []string{".go"}
+	exts := []string{".go"}
+	if h.LiveReload {
+		exts = append(exts, reloadExtensions...)
+	}
+	if ext, ok := h.current().(*externalStrategy); ok {
+		for _, e := range ext.embedExtensions() {
+			if !slices.Contains(exts, e) {
+				exts = append(exts, e)
+			}
+		}
+	}
+	return exts
 }
 
 // UnobservedFiles returns the list of files that should not be tracked by file watchers
 func (h *ServerHandler) UnobservedFiles() []string {
-	if !h.inMemory {
-		if ext, ok := h.strategy.(*externalStrategy); ok {
-			return ext.goCompiler.UnobservedFiles()
-		}
+	if ext, ok := h.current().(*externalStrategy); ok {
+		return append(ext.goCompiler.UnobservedFiles(), filepath.Base(ext.lastGoodPath()))
 	}
 	return []string{}
 }
@@ -129,28 +225,27 @@ func (h *ServerHandler) UnobservedFiles() []string {
 func (h *ServerHandler) SetBuildOnDisk(onDisk bool) {
 	h.buildOnDisk = onDisk
 	// If we are in external mode, it will compile to disk on Start/Restart
-	if !h.inMemory {
+	if h.currentMode() != ModeInMemory {
 		h.Logger("Server BuildOnDisk set to:", onDisk)
 	}
 }
 
-// SetExternalServerMode switches between Internal and External server strategies.
+// SetExternalServerMode switches between the built-in Internal and External
+// server strategies, also away from a strategy set with SetStrategy.
 func (h *ServerHandler) SetExternalServerMode(external bool) {
 	if external {
-		if h.inMemory {
-			h.Logger("Switching to External Server Mode...")
-			h.inMemory = false
-			h.strategy.Stop()
-			h.strategy = newExternalStrategy(h)
-			h.strategy.Start(nil)
+		if h.currentMode() != ModeExternal {
+			h.logEvent(slog.LevelInfo, "Switching to External Server Mode...", LogKeyMode, ModeExternal)
+			h.current().Stop()
+			h.useStrategy(ModeExternal).Start(nil)
 		}
 	} else {
-		if !h.inMemory {
-			h.Logger("Switching to Internal Server Mode...")
-			h.inMemory = true
-			h.strategy.Stop()
-			h.strategy = newInMemoryStrategy(h)
-			h.strategy.Start(nil)
+		if h.currentMode() != ModeInMemory {
+			h.logEvent(slog.LevelInfo, "Switching to Internal Server Mode...", LogKeyMode, ModeInMemory)
+			h.current().Stop()
+			h.useStrategy(ModeInMemory)
+			// In-memory Start blocks until ExitChan
+			go h.StartServer(nil)
 		}
 	}
 }
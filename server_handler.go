@@ -1,5 +1,5 @@
 package server
 
 func (h *ServerHandler) RestartServer() error {
-	return h.strategy.Restart()
+	return h.current().Restart()
 }
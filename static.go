@@ -0,0 +1,297 @@
+package server
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"html"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// publicDirPath is PublicDir resolved against AppRootDir.
+func (h *ServerHandler) publicDirPath() string {
+	if filepath.IsAbs(h.PublicDir) {
+		return h.PublicDir
+	}
+	return filepath.Join(h.AppRootDir, h.PublicDir)
+}
+
+// registerDefaults adds what the generated external server serves to mux
+// unless Routes already did: PublicDir on "/" and a /health route, plus the
+// toolchain's wasm_exec.js on WasmExecPath.
+func (s *inMemoryStrategy) registerDefaults(mux *http.ServeMux) {
+	if p := s.handler.WasmExecPath; !routed(mux, p, p) {
+		s.handleDefault(mux, p, s.handler.wasmExecHandler())
+	}
+	if !routed(mux, "/", "/") {
+		s.handleDefault(mux, "/", s.handler.staticHandler())
+	}
+	if !routed(mux, "/health", "/health") {
+		s.handleDefault(mux, "/health", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("Server is running"))
+		}))
+	}
+}
+
+// handleDefault registers handler on pattern. A route such as "GET /" that
+// conflicts with it keeps the path and the default is skipped; any other
+// registration panic is logged instead of being lost.
+func (s *inMemoryStrategy) handleDefault(mux *http.ServeMux, pattern string, handler http.Handler) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		if msg := fmt.Sprint(r); strings.Contains(msg, "conflicts with") {
+			s.handler.Logger("Default route", pattern, "skipped:", msg)
+		} else {
+			s.handler.logEvent(slog.LevelWarn, "Default route "+pattern+" not registered", LogKeyMode, ModeInMemory, LogKeyError, msg)
+		}
+	}()
+	mux.Handle(pattern, handler)
+}
+
+// routed reports whether a GET for path reaches a route registered for pattern,
+// with or without a method.
+func routed(mux *http.ServeMux, path, pattern string) bool {
+	req, err := http.NewRequest(http.MethodGet, path, nil)
+	if err != nil {
+		return false
+	}
+	_, p := mux.Handler(req)
+	return p == pattern || strings.HasSuffix(p, " "+pattern)
+}
+
+// staticHandler serves PublicDir like the generated server: without caching,
+// from precompressed files or gzip compressed and, with
+// Config.CrossOriginIsolation, with COOP/COEP headers. Config.SPAFallback
+// serves index.html for client-side routes. Until PublicDir exists "/" shows
+// the directory it looks for.
+func (h *ServerHandler) staticHandler() http.Handler {
+	dir := h.publicDirPath()
+	var fs http.Handler = http.FileServer(http.Dir(dir))
+	if h.SPAFallback {
+		fs = spaFallback(dir, h.SPAExcludePrefixes, fs)
+	}
+	files := noCache(precompressed(dir, gzipHandler(fs)))
+	if h.CrossOriginIsolation {
+		files = crossOriginIsolation(files)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dir := h.publicDirPath()
+		if _, err := os.Stat(dir); err != nil && r.URL.Path == "/" {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprintf(w, "<h3>PublicDir not found</h3><p>%s</p>", html.EscapeString(dir))
+			return
+		}
+		files.ServeHTTP(w, r)
+	})
+}
+
+// spaFallback serves index.html for GET requests of paths that do not exist
+// in dir and have no file extension, so client-side routes survive a reload.
+// Missing assets (with an extension) and paths under exclude stay 404.
+func spaFallback(dir string, exclude []string, next http.Handler) http.Handler {
+	index := filepath.Join(dir, "index.html")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Clean("/" + r.URL.Path)
+		if (r.Method == http.MethodGet || r.Method == http.MethodHead) && path.Ext(name) == "" &&
+			!hasAnyPrefix(name+"/", exclude) && !fileExists(filepath.Join(dir, filepath.FromSlash(name))) && fileExists(index) {
+			r = r.Clone(r.Context())
+			r.URL.Path = "/"
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// hasAnyPrefix reports whether s starts with one of prefixes.
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// noCache disables browser caching, assets change on every build.
+func noCache(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store, no-cache, must-revalidate, max-age=0")
+		w.Header().Set("Pragma", "no-cache")
+		w.Header().Set("Expires", "0")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// crossOriginIsolation sends the COOP/COEP headers browsers require before a
+// page may use SharedArrayBuffer (e.g. WASM threads).
+func crossOriginIsolation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cross-Origin-Opener-Policy", "same-origin")
+		w.Header().Set("Cross-Origin-Embedder-Policy", "require-corp")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// precompressedEncodings are tried in order of preference, by file suffix.
+var precompressedEncodings = []struct{ encoding, ext string }{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// precompressed serves file.br or file.gz from dir in place of file when the
+// client accepts that encoding, e.g. app.wasm.br for app.wasm, with the
+// Content-Type of the original file.
+func precompressed(dir string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		setVary(w.Header(), "Accept-Encoding")
+		name := path.Clean("/" + r.URL.Path)
+		if (r.Method != http.MethodGet && r.Method != http.MethodHead) || strings.HasSuffix(r.URL.Path, "/") || r.Header.Get("Range") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		for _, pc := range precompressedEncodings {
+			if acceptsEncoding(r, pc.encoding) && serveEncoded(w, r, name, filepath.Join(dir, filepath.FromSlash(name)+pc.ext), pc.encoding) {
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// serveEncoded serves file, the encoding compressed form of name. It reports
+// false if file does not exist.
+func serveEncoded(w http.ResponseWriter, r *http.Request, name, file, encoding string) bool {
+	f, err := os.Open(file)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		return false
+	}
+	w.Header().Set("Content-Type", contentType(name))
+	w.Header().Set("Content-Encoding", encoding)
+	http.ServeContent(w, r, name, info.ModTime(), f)
+	return true
+}
+
+// contentType of a static file by extension, application/wasm for .wasm
+// whatever the system mime table says, so browsers can compile it streaming.
+func contentType(name string) string {
+	ext := path.Ext(name)
+	if ext == ".wasm" {
+		return "application/wasm"
+	}
+	if ct := mime.TypeByExtension(ext); ct != "" {
+		return ct
+	}
+	return "application/octet-stream"
+}
+
+// acceptsEncoding reports whether r lists encoding in Accept-Encoding without q=0.
+func acceptsEncoding(r *http.Request, encoding string) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		token, params, _ := strings.Cut(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(token), encoding) {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			weight, err := strconv.ParseFloat(q, 64)
+			return err != nil || weight > 0
+		}
+		return true
+	}
+	return false
+}
+
+// setVary adds value to the Vary header unless it is listed already.
+func setVary(h http.Header, value string) {
+	for _, v := range h.Values("Vary") {
+		for _, f := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(f), value) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", value)
+}
+
+// gzipHandler compresses responses for clients that accept gzip.
+func gzipHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		setVary(w.Header(), "Accept-Encoding")
+		if !acceptsEncoding(r, "gzip") || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// Byte ranges of the uncompressed file do not apply to the gzip stream
+		r.Header.Del("Range")
+
+		gw := &gzipResponseWriter{ResponseWriter: w}
+		defer gw.Close()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// gzipResponseWriter compresses the body of successful responses. Errors and
+// bodiless responses (304) are passed through.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz          *gzip.Writer
+	wroteHeader bool
+}
+
+func (w *gzipResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if code == http.StatusOK && w.Header().Get("Content-Encoding") == "" {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Del("Content-Length")
+		w.gz = gzip.NewWriter(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.gz != nil {
+		return w.gz.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *gzipResponseWriter) Close() error {
+	if w.gz != nil {
+		return w.gz.Close()
+	}
+	return nil
+}
+
+// Hijack lets websocket upgrades that assert http.Hijacker through.
+func (w *gzipResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+func (w *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,229 @@
+package server
+
+import (
+	"compress/gzip"
+	"html"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newStaticServer(t *testing.T, routes ...func(*http.ServeMux)) (string, string) {
+	t.Helper()
+	root := t.TempDir()
+	public := filepath.Join(root, "web", "public")
+	if err := os.MkdirAll(public, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(public, "index.html"), []byte("<html><body>INDEX</body></html>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(public, "app.js"), []byte("console.log('app')"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	port := freePort(t)
+	exit := make(chan bool, 1)
+	h := New(&Config{AppRootDir: root, PublicDir: "web/public", AppPort: port, Routes: routes, ExitChan: exit})
+	go h.StartServer(nil)
+	t.Cleanup(func() { exit <- true })
+	return "http://127.0.0.1:" + port, public
+}
+
+func TestInMemoryServesPublicDir(t *testing.T) {
+	base, _ := newStaticServer(t)
+
+	if _, body := getBody(t, base+"/"); body != "<html><body>INDEX</body></html>" {
+		t.Fatalf("expected index.html, got %q", body)
+	}
+	if status, body := getBody(t, base+"/health"); status != http.StatusOK || body != "Server is running" {
+		t.Fatalf("unexpected /health response %d %q", status, body)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, base+"/app.js", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := (&http.Transport{DisableCompression: true}).RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected gzip response, got headers %v", resp.Header)
+	}
+	if resp.Header.Get("Cache-Control") != "no-store, no-cache, must-revalidate, max-age=0" {
+		t.Fatalf("expected no-cache headers, got %q", resp.Header.Get("Cache-Control"))
+	}
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(gz); string(body) != "console.log('app')" {
+		t.Fatalf("unexpected app.js body %q", body)
+	}
+}
+
+func TestInMemoryRoutesOverridePublicDir(t *testing.T) {
+	base, _ := newStaticServer(t, textRoute("/", "CUSTOM"), textRoute("/health", "OWN HEALTH"))
+
+	if _, body := getBody(t, base+"/"); body != "CUSTOM" {
+		t.Fatalf("expected the registered / route, got %q", body)
+	}
+	if _, body := getBody(t, base+"/health"); body != "OWN HEALTH" {
+		t.Fatalf("expected the registered /health route, got %q", body)
+	}
+}
+
+func TestInMemoryDefaultsSkipConflictingRoutes(t *testing.T) {
+	// "/health" would conflict with "GET /", the registered route wins
+	base, _ := newStaticServer(t, textRoute("GET /", "CUSTOM"))
+
+	if _, body := getBody(t, base+"/health"); body != "CUSTOM" {
+		t.Fatalf("expected the registered GET / route, got %q", body)
+	}
+}
+
+func TestInMemoryWithoutPublicDir(t *testing.T) {
+	base, public := newStaticServer(t)
+	if err := os.RemoveAll(public); err != nil {
+		t.Fatal(err)
+	}
+	_, body := getBody(t, base+"/")
+	if !strings.Contains(body, "PublicDir not found") || !strings.Contains(body, html.EscapeString(public)) {
+		t.Fatalf("expected a page naming the missing PublicDir %s, got %q", public, body)
+	}
+}
+
+func TestInMemoryServesPrecompressedWasm(t *testing.T) {
+	base, public := newStaticServer(t)
+	files := map[string]string{"app.wasm": "RAW", "app.wasm.br": "BROTLI", "app.wasm.gz": "GZIP"}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(public, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	getBody(t, base+"/health")
+
+	for _, tc := range []struct{ accept, encoding, body string }{
+		{"gzip, deflate, br", "br", "BROTLI"},
+		{"gzip", "gzip", "GZIP"},
+		{"br;q=0, gzip", "gzip", "GZIP"},
+		{"", "", "RAW"},
+	} {
+		req, _ := http.NewRequest(http.MethodGet, base+"/app.wasm", nil)
+		req.Header.Set("Accept-Encoding", tc.accept)
+		resp, err := (&http.Transport{DisableCompression: true}).RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if string(body) != tc.body || resp.Header.Get("Content-Encoding") != tc.encoding {
+			t.Errorf("Accept-Encoding %q: got %q with encoding %q", tc.accept, body, resp.Header.Get("Content-Encoding"))
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != "application/wasm" {
+			t.Errorf("Accept-Encoding %q: expected application/wasm, got %q", tc.accept, ct)
+		}
+		if vary := resp.Header.Values("Vary"); len(vary) != 1 || vary[0] != "Accept-Encoding" {
+			t.Errorf("Accept-Encoding %q: expected a single Vary: Accept-Encoding, got %v", tc.accept, vary)
+		}
+	}
+}
+
+func TestCrossOriginIsolationHeaders(t *testing.T) {
+	for _, isolated := range []bool{false, true} {
+		root := t.TempDir()
+		port := freePort(t)
+		exit := make(chan bool, 1)
+		h := New(&Config{AppRootDir: root, PublicDir: ".", AppPort: port, ExitChan: exit, CrossOriginIsolation: isolated})
+		go h.StartServer(nil)
+
+		getBody(t, "http://127.0.0.1:"+port+"/health")
+		resp, err := http.Get("http://127.0.0.1:" + port + "/")
+		exit <- true
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		coop, coep := resp.Header.Get("Cross-Origin-Opener-Policy"), resp.Header.Get("Cross-Origin-Embedder-Policy")
+		if isolated && (coop != "same-origin" || coep != "require-corp") {
+			t.Errorf("expected isolation headers, got COOP %q COEP %q", coop, coep)
+		}
+		if !isolated && (coop != "" || coep != "") {
+			t.Errorf("isolation headers sent without CrossOriginIsolation: COOP %q COEP %q", coop, coep)
+		}
+	}
+}
+
+func TestSPAFallback(t *testing.T) {
+	root := t.TempDir()
+	public := filepath.Join(root, "public")
+	if err := os.MkdirAll(filepath.Join(public, "assets"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"index.html": "INDEX", "assets/app.js": "APP"} {
+		if err := os.WriteFile(filepath.Join(public, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, spa := range []bool{false, true} {
+		h := New(&Config{AppRootDir: root, PublicDir: "public", SPAFallback: spa, SPAExcludePrefixes: []string{"/api/"}})
+		static := h.staticHandler()
+		cases := []struct {
+			method, path string
+			status       int
+			body         string
+		}{
+			{http.MethodGet, "/users/42", http.StatusOK, "INDEX"},
+			{http.MethodHead, "/settings", http.StatusOK, ""},
+			{http.MethodGet, "/assets/app.js", http.StatusOK, "APP"},
+			{http.MethodGet, "/assets/missing.js", http.StatusNotFound, ""},
+			{http.MethodGet, "/api/users", http.StatusNotFound, ""},
+			{http.MethodGet, "/api", http.StatusNotFound, ""},
+			{http.MethodPost, "/users/42", http.StatusNotFound, ""},
+		}
+		for _, tc := range cases {
+			rec := httptest.NewRecorder()
+			static.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, nil))
+			status := tc.status
+			if !spa && tc.status == http.StatusOK && tc.path != "/assets/app.js" {
+				status = http.StatusNotFound
+			}
+			if rec.Code != status {
+				t.Errorf("SPAFallback %v: %s %s: expected %d, got %d", spa, tc.method, tc.path, status, rec.Code)
+				continue
+			}
+			if status == http.StatusOK && tc.body != "" && rec.Body.String() != tc.body {
+				t.Errorf("SPAFallback %v: %s %s: expected %q, got %q", spa, tc.method, tc.path, tc.body, rec.Body.String())
+			}
+		}
+	}
+}
+
+func TestHandleDefaultLogsRecoveredPanics(t *testing.T) {
+	var logs []string
+	h := New(&Config{AppRootDir: t.TempDir()})
+	h.SetLog(func(messages ...any) { logs = append(logs, plainMessage(messages...)) })
+	s := newInMemoryStrategy(h)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /", func(w http.ResponseWriter, r *http.Request) {})
+	s.handleDefault(mux, "/health", http.NotFoundHandler())
+	s.handleDefault(mux, "/{broken", http.NotFoundHandler())
+
+	if len(logs) != 2 {
+		t.Fatalf("expected both recovered panics to be logged, got %q", logs)
+	}
+	if !strings.Contains(logs[0], "Default route /health skipped") || !strings.Contains(logs[0], "conflicts with") {
+		t.Errorf("unexpected conflict record %q", logs[0])
+	}
+	if !strings.Contains(logs[1], "Default route /{broken not registered") || !strings.Contains(logs[1], "error=parsing") {
+		t.Errorf("unexpected warning %q", logs[1])
+	}
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"net"
+	"time"
+)
+
+// Status is a snapshot of what the server is doing, see ServerHandler.Status.
+type Status struct {
+	Mode      string        // ModeExternal, ModeInMemory or the name of a registered strategy
+	Strategy  string        // ServerStrategy.Name of the active strategy
+	Addr      string        // address the server listens on e.g. ":8080", empty when not listening
+	PID       int           // external server process ID, 0 in In-Memory mode or when not running
+	Uptime    time.Duration // since the current server or process started, 0 when not running
+	BuiltAt   time.Time     // when the running external binary was compiled
+	BuildTook time.Duration // how long that compile took
+	LastError error         // why the latest external build or start failed, nil if it succeeded
+	Restarts  int           // times the server was started again since the strategy started
+	Stale     bool          // an older external binary keeps serving after a failed build
+}
+
+// Status reports the current mode and the state of the running server.
+func (h *ServerHandler) Status() Status {
+	h.mu.RLock()
+	strategy, mode := h.strategy, h.mode
+	h.mu.RUnlock()
+	st := Status{Mode: mode, Strategy: strategy.Name()}
+
+	switch s := strategy.(type) {
+	case *inMemoryStrategy:
+		s.mu.Lock()
+		if s.running && s.server != nil {
+			st.Addr = net.JoinHostPort("", h.Port())
+			st.Uptime = time.Since(s.started)
+		}
+		st.Restarts = s.restarts
+		s.mu.Unlock()
+	case *externalStrategy:
+		build := s.buildStatus()
+		st.BuiltAt, st.BuildTook, st.LastError, st.Stale = build.BuiltAt, build.BuildTook, build.LastError, build.Stale()
+
+		s.mu.Lock()
+		p, port := s.process, s.port
+		if s.proxy != nil {
+			port = h.Port()
+		}
+		st.Restarts = max(s.launches-1, 0)
+		s.mu.Unlock()
+
+		if build.Running {
+			st.Addr = net.JoinHostPort("", port)
+			st.PID = p.PID()
+			st.Uptime = time.Since(p.StartedAt())
+		}
+	}
+	return st
+}
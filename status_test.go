@@ -0,0 +1,114 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+)
+
+// memStore is an in-memory Store.
+type memStore map[string]string
+
+func (m memStore) Get(key string) (string, error) { return m[key], nil }
+func (m memStore) Set(key, value string) error    { m[key] = value; return nil }
+
+func TestStatusInMemory(t *testing.T) {
+	port := freePort(t)
+	exit := make(chan bool, 1)
+	h := New(&Config{
+		AppRootDir: t.TempDir(),
+		AppPort:    port,
+		Routes:     []func(*http.ServeMux){textRoute("/v", "v1")},
+		ExitChan:   exit,
+	})
+	go h.StartServer(nil)
+	defer func() { exit <- true }()
+	getBody(t, "http://127.0.0.1:"+port+"/v")
+
+	st := h.Status()
+	if st.Mode != ModeInMemory || st.Strategy != "In-Memory" {
+		t.Fatalf("unexpected mode %q strategy %q", st.Mode, st.Strategy)
+	}
+	if st.Addr != ":"+port || st.Uptime <= 0 || st.PID != 0 {
+		t.Fatalf("unexpected listening state: %+v", st)
+	}
+
+	if err := h.RestartServer(); err != nil {
+		t.Fatalf("RestartServer: %v", err)
+	}
+	if st := h.Status(); st.Restarts != 1 {
+		t.Fatalf("expected 1 restart, got %d", st.Restarts)
+	}
+
+	ui := NewServerModeHandler(h, memStore{StoreKeyExternalServer: "true"}, nil)
+	if label := ui.Label(); label != "SERVER: INTERNAL" {
+		t.Fatalf("Label should follow the running mode, not the Store: %q", label)
+	}
+}
+
+func TestStatusExternal(t *testing.T) {
+	h, base := newCrashingServer(t, true, 5)
+	getBody(t, base)
+
+	st := h.Status()
+	if st.Mode != ModeExternal || st.Strategy != "External Process" {
+		t.Fatalf("unexpected mode %q strategy %q", st.Mode, st.Strategy)
+	}
+	if st.PID == 0 || st.Addr != ":"+h.AppPort || st.Uptime <= 0 {
+		t.Fatalf("unexpected process state: %+v", st)
+	}
+	if st.BuiltAt.IsZero() || st.BuildTook <= 0 || st.LastError != nil || st.Stale {
+		t.Fatalf("unexpected build state: %+v", st)
+	}
+
+	if err := h.RestartServer(); err != nil {
+		t.Fatalf("RestartServer: %v", err)
+	}
+	if st := h.Status(); st.Restarts != 1 {
+		t.Fatalf("expected 1 restart, got %d", st.Restarts)
+	}
+
+	store := memStore{}
+	ui := NewServerModeHandler(h, store, nil)
+	if label := ui.Label(); label != "SERVER: EXTERNAL" {
+		t.Fatalf("unexpected label %q", label)
+	}
+	ui.Execute()
+	if store[StoreKeyExternalServer] != "false" || h.Status().Mode != ModeInMemory {
+		t.Fatalf("Execute should switch to in-memory, store %v mode %q", store, h.Status().Mode)
+	}
+}
+
+func TestStatusDuringModeSwitches(t *testing.T) {
+	exit := make(chan bool, 1)
+	h := New(&Config{AppRootDir: t.TempDir(), AppPort: freePort(t), ExitChan: exit})
+	h.RegisterStrategy("fake", func(h *ServerHandler) ServerStrategy { return newFakeStrategy() })
+
+	// A dashboard polls Status while the strategy is replaced
+	done := make(chan struct{})
+	polled := make(chan struct{})
+	go func() {
+		defer close(polled)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				h.Status()
+			}
+		}
+	}()
+
+	for range 3 {
+		if err := h.SetStrategy("fake"); err != nil {
+			t.Fatalf("SetStrategy: %v", err)
+		}
+		h.SetExternalServerMode(false)
+	}
+	close(done)
+	<-polled
+
+	if st := h.Status(); st.Mode != ModeInMemory {
+		t.Fatalf("expected in-memory mode, got %q", st.Mode)
+	}
+	exit <- true
+}
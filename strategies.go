@@ -4,22 +4,28 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
-	"path"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/tinywasm/gobuild"
-	"github.com/tinywasm/gorun"
 )
 
 type ServerStrategy interface {
 	Start(wg *sync.WaitGroup) error
 	Stop() error
+	// Run starts the server and blocks until ctx is cancelled, then shuts it
+	// down, or until the strategy is stopped (e.g. replaced by a mode switch).
+	Run(ctx context.Context) error
+	// Shutdown stops the server, ctx bounds the graceful part.
+	Shutdown(ctx context.Context) error
 	Restart() error
 	HandleFileEvent(fileName, extension, filePath, event string) error
 	Name() string
@@ -28,10 +34,16 @@ type ServerStrategy interface {
 // --- In-Memory Strategy ---
 
 type inMemoryStrategy struct {
-	handler *ServerHandler
-	server  *http.Server
-	mu      sync.Mutex
-	running bool
+	handler  *ServerHandler
+	mux      atomic.Pointer[http.ServeMux] // swapped by ReplaceRoutes while server keeps running
+	runMu    sync.Mutex                    // serializes start, Shutdown, Restart and swapRoutes, held while connections drain
+	mu       sync.Mutex                    // guards the fields below, so Status does not wait for a drain
+	server   *http.Server
+	ln       net.Listener // the listener of server, which Serve may not have taken over yet
+	running  bool
+	stopped  chan struct{} // closed by Stop to release the goroutine blocked in Start
+	started  time.Time     // when the current http.Server started listening
+	restarts int
 }
 
 func newInMemoryStrategy(h *ServerHandler) *inMemoryStrategy {
@@ -44,94 +56,224 @@ func (s *inMemoryStrategy) Name() string {
 	return "In-Memory"
 }
 
-func (s *inMemoryStrategy) Start(wg *sync.WaitGroup) error {
-	s.mu.Lock()
+// start binds AppPort and serves in the background. It returns the channel
+// closed by Shutdown and false if the server was already running.
+func (s *inMemoryStrategy) start() (stopped <-chan struct{}, started bool, err error) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
 	if s.running {
-		s.mu.Unlock()
+		return s.stopped, false, nil
+	}
+	if err := s.listen(); err != nil {
+		return nil, false, err
+	}
+	s.mu.Lock()
+	s.running = true
+	s.stopped = make(chan struct{})
+	s.mu.Unlock()
+	return s.stopped, true, nil
+}
+
+func (s *inMemoryStrategy) Start(wg *sync.WaitGroup) error {
+	stopped, started, err := s.start()
+	if err != nil || !started {
 		if wg != nil {
 			wg.Done()
 		}
-		return nil
+		return err
 	}
-	s.running = true
-	s.mu.Unlock()
 
 	// WaitGroup Done is handled at the end of this function (blocking until exit)
 
+	// Block until exit signal received, or until the strategy is replaced
+	// so the signal is left to the next one
+	select {
+	case <-s.handler.ExitChan:
+	case <-stopped:
+	}
+
+	// Stop the server
+	s.Stop()
+
+	if wg != nil {
+		wg.Done()
+	}
+
+	return nil
+}
+
+// newMux builds a fresh mux from routes.
+func (s *inMemoryStrategy) newMux(routes []func(*http.ServeMux)) *http.ServeMux {
 	mux := http.NewServeMux()
 
-	if len(s.handler.Routes) > 0 {
-		for _, registerConfig := range s.handler.Routes {
-			registerConfig(mux)
-		}
-	} else {
-		// Default handler if no routes provided
-		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Content-Type", "text/html; charset=utf-8")
-			fmt.Fprint(w, "<h3>No routes registered in In-Memory Server</h3>")
-		})
+	for _, registerConfig := range routes {
+		registerConfig(mux)
 	}
+	// Serve PublicDir and /health like the generated server, unless Routes do
+	s.registerDefaults(mux)
+	return mux
+}
 
-	s.server = &http.Server{
-		Addr:    ":" + s.handler.AppPort,
-		Handler: mux,
+// listen binds AppPort (see Config.PortPolicy) and serves a new http.Server on it.
+// Must be called with s.runMu held.
+func (s *inMemoryStrategy) listen() error {
+	ln, err := s.handler.listenAppPort()
+	if err != nil {
+		s.handler.logEvent(slog.LevelError, "In-Memory Server error", LogKeyMode, ModeInMemory, LogKeyPort, s.handler.AppPort, LogKeyError, err)
+		return err
 	}
 
-	s.handler.Logger("Starting In-Memory Server on port:", s.handler.AppPort)
+	s.mux.Store(s.newMux(s.handler.routes()))
+	srv := &http.Server{
+		Handler: s.handler.liveReload(chain(http.HandlerFunc(s.serveHTTP), s.handler.Middleware)),
+	}
+	srv.RegisterOnShutdown(s.handler.reload.closeAll)
+	s.mu.Lock()
+	s.server, s.ln = srv, ln
+	s.started = time.Now()
+	s.mu.Unlock()
 
-	// Capture server instance to avoid race condition with Stop() setting s.server = nil
-	srv := s.server
+	port := s.handler.Port()
+	s.handler.logEvent(slog.LevelInfo, "Starting In-Memory Server", LogKeyMode, ModeInMemory, LogKeyPort, port)
+	// The listener is bound, connections queue until Serve picks them up
+	s.handler.emit(Event{Type: EventReady, Mode: ModeInMemory, Port: port})
 
 	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			s.handler.Logger("In-Memory Server error:", err)
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			s.handler.logEvent(slog.LevelError, "In-Memory Server error", LogKeyMode, ModeInMemory, LogKeyPort, port, LogKeyError, err)
 		}
 	}()
+	return nil
+}
 
-	// Block until exit signal received
-	if s.handler.ExitChan != nil {
-		<-s.handler.ExitChan
-	}
+// serveHTTP dispatches to the current mux, so swapping it does not touch open connections.
+func (s *inMemoryStrategy) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.Load().ServeHTTP(w, r)
+}
 
-	// Stop the server
-	s.Stop()
+// swapRoutes builds a mux from routes, swaps it in behind the running server
+// and stores routes in Config.Routes. A route function that panics (e.g.
+// duplicate pattern) keeps the previous routes in place.
+func (s *inMemoryStrategy) swapRoutes(routes []func(*http.ServeMux)) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("registering routes: %v", r)
+		}
+	}()
 
-	if wg != nil {
-		wg.Done()
+	// Keeps a concurrent Restart from serving a mux of the previous routes
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	if !s.running {
+		s.handler.setRoutes(routes)
+		return nil
 	}
-
+	s.mux.Store(s.newMux(routes))
+	s.handler.setRoutes(routes)
+	s.handler.Logger("In-Memory Server routes replaced")
+	s.handler.Reload()
 	return nil
 }
 
-func (s *inMemoryStrategy) Stop() error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	if !s.running || s.server == nil {
+// Run serves until ctx is cancelled or the strategy is stopped. Unlike Start it
+// ignores Config.ExitChan.
+func (s *inMemoryStrategy) Run(ctx context.Context) error {
+	stopped, _, err := s.start()
+	if err != nil {
+		return err
+	}
+	select {
+	case <-ctx.Done():
+		sctx, cancel := s.handler.shutdownContext(ctx)
+		defer cancel()
+		return s.Shutdown(sctx)
+	case <-stopped:
 		return nil
 	}
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// Stop shuts down the server, giving open connections StopGracePeriod.
+func (s *inMemoryStrategy) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.handler.StopGracePeriod)
 	defer cancel()
+	return s.Shutdown(ctx)
+}
+
+// Shutdown closes the listener and waits for open connections until ctx ends,
+// then closes the ones still open.
+func (s *inMemoryStrategy) Shutdown(ctx context.Context) error {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	if !s.running {
+		return nil
+	}
 
-	err := s.server.Shutdown(ctx)
+	s.mu.Lock()
+	srv, ln := s.server, s.ln
 	s.running = false
-	s.server = nil
-	s.handler.Logger("In-Memory Server stopped")
+	s.server, s.ln = nil, nil
+	close(s.stopped)
+	s.mu.Unlock()
+
+	var err error
+	if srv != nil {
+		if err = srv.Shutdown(ctx); err != nil {
+			srv.Close()
+		}
+		ln.Close()
+	}
+	s.handler.logEvent(slog.LevelInfo, "In-Memory Server stopped", LogKeyMode, ModeInMemory)
+	s.handler.emit(Event{Type: EventStopped, Mode: ModeInMemory})
 	return err
 }
 
+// Restart shuts down the current http.Server and serves a fresh mux built from
+// the current Routes and AppPort. The goroutine blocked in Start keeps waiting on
+// ExitChan and stops whichever server is current when the signal arrives.
+// Connections still open after StopGracePeriod (e.g. a long poll) are closed.
 func (s *inMemoryStrategy) Restart() error {
-	// For in-memory, restart isn't typically needed unless config changes,
-	// but we can implement it as Stop + Start.
-	// Note: Start requires WaitGroup, which complicates direct restart here if we strictly follow interface.
-	// For now, we'll just Log. In-memory usually doesn't "restart" on file changes in the same way.
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	if !s.running {
+		return nil
+	}
+
+	s.mu.Lock()
+	srv, ln := s.server, s.ln
+	s.server, s.ln = nil, nil
+	s.mu.Unlock()
+
+	// srv is nil when the previous Restart could not bind
+	if srv != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), s.handler.StopGracePeriod)
+		defer cancel()
+
+		if err := srv.Shutdown(ctx); err != nil {
+			s.handler.logEvent(slog.LevelWarn, "Closing connections still open after StopGracePeriod", LogKeyMode, ModeInMemory, LogKeyError, err)
+			srv.Close()
+		}
+		// Shutdown only closes the listener once Serve picked it up
+		ln.Close()
+	}
+
+	if err := s.listen(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.restarts++
+	s.mu.Unlock()
+	s.handler.logEvent(slog.LevelInfo, "In-Memory Server restarted", LogKeyMode, ModeInMemory, LogKeyPort, s.handler.Port())
 	return nil
 }
 
 func (s *inMemoryStrategy) HandleFileEvent(fileName, extension, filePath, event string) error {
 	// In-memory server typically doesn't react to file events unless we want to hot-reload assets.
-	// For now, no-op or specific logic if requested.
+	// The exception is the main input file coming back after external mode fell back to memory.
+	if (event == "create" || event == "write") && s.handler.mainInputRemoved.Load() && s.handler.isMainInputFile(filePath) {
+		return s.handler.restoreExternalMode()
+	}
 	return nil
 }
 
@@ -140,7 +282,27 @@ func (s *inMemoryStrategy) HandleFileEvent(fileName, extension, filePath, event
 type externalStrategy struct {
 	handler    *ServerHandler
 	goCompiler *gobuild.GoBuild
-	goRun      *gorun.GoRun
+	process    *serverProcess
+	proxy      *frontProxy   // nil unless Config.ProxyMode or after handOver (Config.ProxyOnRebuild), set under runMu and mu
+	overlay    *errorOverlay // compiler output served on AppPort while the build is broken
+	debouncer  *rebuildDebouncer
+
+	runMu      sync.Mutex  // serializes startServer and Stop
+	port       string      // port the current binary listens on (AppPort, or internal port in ProxyMode)
+	built      buildInputs // inputs of the binary on disk (the last good build)
+	stopped    bool        // Stop was called, no builds or crash restarts until the next Start or Run
+	direct     bool        // the binary ignored the port handOver gave it, swap by stopping the previous process
+	builds     int         // successful compiles so far, numbers the builds
+	buildID    int         // build of the binary on disk, tags the process output
+	goodID     int         // build of the last binary that passed readiness
+	mu         sync.Mutex
+	deps       buildInputs   // inputs of the last compile attempt, used to filter file events
+	status     BuildStatus   // Running is filled in by buildStatus
+	crashTimer *time.Timer   // pending restart after a crash
+	done       chan struct{} // closed by Shutdown to release Run
+	launches   int           // binaries started successfully, restarts included
+	watching   chan bool     // ExitChan currently watched by watchExit
+	unwatch    chan struct{} // closed by Stop to end the watchExit goroutine
 }
 
 func newExternalStrategy(h *ServerHandler) *externalStrategy {
@@ -173,20 +335,77 @@ func newExternalStrategy(h *ServerHandler) *externalStrategy {
 		Timeout:                   30 * time.Second,
 	})
 
-	runner := gorun.New(&gorun.Config{
-		ExecProgramPath: "./" + compiler.MainOutputFileNameWithExtension(),
-		RunArguments:    h.ArgumentsToRunServer,
-		ExitChan:        h.ExitChan,
-		Logger:          h.Logger,
-		KillAllOnStop:   true,
-		WorkingDir:      filepath.Join(h.AppRootDir, h.OutputDir),
-	})
-
-	return &externalStrategy{
+	s := &externalStrategy{
 		handler:    h,
 		goCompiler: compiler,
-		goRun:      runner,
+		port:       h.AppPort,
+		overlay:    &errorOverlay{handler: h},
+	}
+	s.process = s.newProcess(h.AppPort)
+
+	if h.ProxyMode {
+		s.proxy = newFrontProxy(h, s.overlay)
+	} else if h.LiveReload {
+		// The binary owns AppPort until handOver, so nothing serves ReloadPath or injects the script
+		h.logEvent(slog.LevelWarn, "LiveReload needs ProxyMode in External mode, browsers are not reloaded while the binary owns AppPort", LogKeyMode, ModeExternal)
+	}
+
+	s.debouncer = &rebuildDebouncer{
+		delay: h.DebounceDelay,
+		rebuild: func(paths []string) {
+			if len(paths) > 1 {
+				h.Logger("Merged", len(paths), "file events into one rebuild")
+			}
+			s.rebuild(paths)
+		},
+		cancel: func() { compiler.Cancel() },
+	}
+
+	return s
+}
+
+// newProcess prepares a run of the compiled binary listening on port.
+// Its output is tagged with the ID of the current build and of each run.
+func (s *externalStrategy) newProcess(port string) *serverProcess {
+	build := s.buildID
+	return &serverProcess{
+		execPath:   "./" + s.goCompiler.MainOutputFileNameWithExtension(),
+		workingDir: filepath.Join(s.handler.AppRootDir, s.handler.OutputDir),
+		args:       func() []string { return s.runArguments(port) },
+		env:        func() []string { return s.runEnv(port) },
+		logger:     s.handler.Logger,
+		output: func(run, pid int, stream, line string) {
+			s.handler.output.add(OutputLine{Stream: stream, BuildID: build, RunID: run, PID: pid, Text: line})
+		},
+		nextRun: s.handler.output.newRun,
+		exited: func(pid int, err error) {
+			s.handler.emit(Event{Type: EventProcessExited, PID: pid, ExitCode: exitCode(err)})
+		},
+	}
+}
+
+// passesPort reports whether the binary is told its port: the internal port in
+// ProxyMode, or the port picked for AppPort "0" or by a PortPolicy other than
+// PortStrict.
+func (s *externalStrategy) passesPort() bool {
+	return s.proxy != nil || s.handler.PortPolicy != PortStrict || s.handler.AppPort == "0"
+}
+
+// runArguments passes the port first (see passesPort), so flag.Parse in the
+// binary sees it before any positional ArgumentsToRunServer.
+func (s *externalStrategy) runArguments(port string) []string {
+	args := s.handler.ArgumentsToRunServer()
+	if s.passesPort() {
+		args = append([]string{"-port=" + port}, args...)
+	}
+	return args
+}
+
+func (s *externalStrategy) runEnv(port string) []string {
+	if s.passesPort() {
+		return []string{"PORT=" + port}
 	}
+	return nil
 }
 
 func (s *externalStrategy) Name() string {
@@ -199,73 +418,523 @@ func (s *externalStrategy) Start(wg *sync.WaitGroup) error {
 			wg.Done()
 		}
 	}()
-	return s.startServer()
+
+	s.resume()
+	if s.proxy != nil {
+		if err := s.proxy.Start(); err != nil {
+			return errors.Join(errors.New("starting proxy"), err)
+		}
+	}
+	s.watchExit()
+
+	return s.startServer(nil)
+}
+
+// resume lets a stopped strategy build and launch again. Only Start and Run
+// call it: a file event or debounced rebuild arriving after Stop must not.
+func (s *externalStrategy) resume() {
+	s.runMu.Lock()
+	s.stopped = false
+	s.runMu.Unlock()
+}
+
+// watchExit stops the strategy when the global ExitChan fires, as gorun did.
+func (s *externalStrategy) watchExit() {
+	exit := s.handler.ExitChan
+	if exit == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.watching == exit {
+		return
+	}
+	s.watching = exit
+	s.unwatch = make(chan struct{})
+	unwatch := s.unwatch
+
+	go func() {
+		select {
+		case <-exit:
+			s.Stop()
+		case <-unwatch:
+		}
+	}()
 }
 
-func (s *externalStrategy) startServer() error {
+// startServer compiles and launches the server. read holds the build inputs
+// the caller just read, nil to read them here.
+func (s *externalStrategy) startServer(read *buildInputs) error {
 	e := errors.New("startServer")
 
-	// ALWAYS COMPILE before running
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	if s.stopped {
+		return nil // stopped or replaced by a mode switch
+	}
+
+	var inputs buildInputs
+	if read != nil {
+		inputs = *read
+	} else {
+		var err error
+		if inputs, err = s.handler.readBuildInputs(); err != nil {
+			// Not fatal, file events just won't be filtered or able to skip the compile
+			s.handler.Logger("Reading server dependencies:", err)
+			inputs = buildInputs{}
+		}
+	}
+	s.mu.Lock()
+	s.deps = inputs
+	s.mu.Unlock()
+
+	// ALWAYS COMPILE before running. The running process keeps serving meanwhile.
+	file := s.handler.MainInputFileRelativePath()
+	s.handler.logEvent(slog.LevelInfo, "Compiling server", LogKeyMode, ModeExternal, LogKeyFile, file)
+	s.handler.emit(Event{Type: EventBuildStarted, BuildID: s.builds + 1})
+	compileStart := time.Now()
 	err := s.goCompiler.CompileProgram()
+	took := time.Since(compileStart)
 	if err != nil {
+		if isIgnoredRestartError(err) {
+			s.handler.logEvent(slog.LevelInfo, "Compile cancelled", LogKeyFile, file, LogKeyDuration, took)
+		} else {
+			s.handler.logEvent(slog.LevelError, "Compile failed", LogKeyFile, file, LogKeyDuration, took, LogKeyError, err)
+			s.buildFailed(err)
+		}
 		return errors.Join(e, err)
 	}
+	s.handler.logEvent(slog.LevelInfo, "Server compiled", LogKeyFile, file, LogKeyBuild, s.builds+1, LogKeyDuration, took)
 
-	// RUN
-	err = s.goRun.RunProgram()
-	if err != nil {
+	s.builds++
+	s.buildID = s.builds
+	s.handler.emit(Event{Type: EventBuildSucceeded, BuildID: s.buildID})
+
+	// A new build starts with a clean crash record
+	s.stopCrashRestarts()
+	s.mu.Lock()
+	s.status.Crashes, s.status.LastCrash, s.status.CrashLoop = 0, nil, false
+	s.mu.Unlock()
+
+	if err := s.launch(time.Now(), took); err != nil {
 		return errors.Join(e, err)
 	}
+	s.built = inputs
+	return nil
+}
+
+// launch swaps in the binary on disk, built at builtAt in took, and records
+// the outcome. Must be called with s.runMu held.
+func (s *externalStrategy) launch(builtAt time.Time, took time.Duration) error {
+	if err := s.swap(); err != nil {
+		s.buildFailed(err)
+		var notReady *readinessError
+		if errors.As(err, &notReady) && !s.process.Running() {
+			// The binary exited on startup (e.g. log.Fatal), retry with backoff
+			s.crashed(CrashReport{Time: time.Now(), ExitCode: exitCode(err), Stderr: notReady.stderr}, false)
+		}
+		return err
+	}
 
-	s.handler.Logger("Started:", path.Join(s.handler.SourceDir, s.handler.mainFileExternalServer), "Port:", s.handler.AppPort)
+	s.mu.Lock()
+	s.status.BuiltAt, s.status.BuildTook = builtAt, took
+	s.status.LastError, s.status.FailedAt = nil, time.Time{}
+	s.launches++
+	s.mu.Unlock()
+
+	s.handler.logEvent(slog.LevelInfo, "External server started", LogKeyMode, ModeExternal,
+		LogKeyFile, s.handler.MainInputFileRelativePath(), LogKeyPort, s.port, LogKeyPID, s.process.PID(), LogKeyBuild, s.buildID)
+	s.handler.Reload()
 	return nil
 }
 
-func (s *externalStrategy) Stop() error {
-	// gorun handles kill on stop/exit via ExitChan, but if we need explicit stop:
-	// For now, we assume the system handles it via the ExitChan in ServerHandler or similar.
-	// But to strictly implement Stop for switching strategies:
-	if s.goRun != nil {
-		// gorun doesn't expose a direct Stop method if not waiting on ExitChan?
-		// We might need to send to ExitChan provided to gorun.
-		// However, s.handler.ExitChan is shared.
-		// Let's assume for this refactor we might need to manually kill if switching.
-		// But gorun.New took ExitChan.
-	}
-	// Important: If we are switching strategies, we MUST kill the external process.
-	// Since gorun logic listens on ExitChan, we might be able to leverage that OR
-	// we rely on the fact that `gorun` kills process when the struct is discarded? No.
-	// We need to implement a way to stop it.
-	// Looking at gorun source (assumed), it likely listens to ExitChan.
-	// If we repurpose ExitChan, we stop everything.
-	// We might need to send a signal specifically to this runner.
-	// Let's look at `gorun` interface if possible.
-	// For now, I'll assumme we can't easily "Stop" it without `ExitChan` which stops app.
-	// Wait, `gorun` usually runs until `ExitChan` receives?
-	// If we want to switch modes at runtime, we need to stop the old one.
-
-	// Strategy: Trigger a restart/stop on the runner if possible.
-	// I'll assume for now we might need to rely on OS kill if gorun doesn't export Stop.
-	// But let's look at the previous `goserver.go`... it passed `c.ExitChan`.
+// swap starts the binary on disk and makes it the current process once it
+// passed readiness. In ProxyMode it runs next to the previous process, which
+// keeps serving until the proxy is switched over. With ProxyOnRebuild the
+// first swap with a process running hands AppPort over to a proxy (see
+// handOver). Otherwise the previous process has to release AppPort first and
+// is started again from the last good binary if the new one never becomes ready.
+func (s *externalStrategy) swap() error {
+	if s.proxy == nil && s.handler.ProxyOnRebuild && s.process.Running() && !s.direct {
+		if handled, err := s.handOver(); handled {
+			return err
+		}
+	}
+
+	prev, prevPort := s.process, s.port
+	port := s.handler.AppPort
+
+	if s.proxy != nil {
+		p, err := freeLocalPort()
+		if err != nil {
+			return err
+		}
+		port = p
+		if !prev.Running() {
+			// Nothing to serve meanwhile, hold requests until the binary is ready
+			s.proxy.Hold()
+		}
+	} else {
+		if err := s.stopProcess(s.handler.StopGracePeriod); err != nil {
+			return err
+		}
+		// The error page may hold AppPort
+		if err := s.overlay.Clear(); err != nil {
+			return err
+		}
+		if s.passesPort() {
+			p, err := s.handler.reserveAppPort()
+			if err != nil {
+				return err
+			}
+			port = p
+		}
+		// Another program holding the port would pass the readiness probe
+		// while the binary fails to bind
+		if err := waitPortFree(port, time.Second); err != nil {
+			return err
+		}
+	}
+
+	next := s.newProcess(port)
+	err := next.Start()
+	if err == nil {
+		s.processStarted(next)
+		if err = s.waitReady(next, port); err != nil {
+			next.Stop(s.handler.StopGracePeriod)
+		}
+	}
+	if err != nil {
+		if restored := s.restoreLastGood(); restored && s.proxy == nil && prev.Started() {
+			s.handler.Logger("New build not ready, restarting the previous one")
+			if perr := prev.Start(); perr == nil {
+				s.processStarted(prev)
+				if perr = s.waitReady(prev, prevPort); perr != nil {
+					prev.Stop(s.handler.StopGracePeriod)
+				} else {
+					s.supervise(prev)
+					s.ready(prev, prevPort)
+				}
+			}
+		}
+		return err
+	}
+
+	s.mu.Lock()
+	s.process, s.port = next, port
+	s.mu.Unlock()
+	s.supervise(next)
+
+	if s.proxy != nil {
+		s.proxy.Release(port)
+		s.overlay.Clear()
+		prev.Stop(s.handler.StopGracePeriod)
+	}
+	s.saveLastGood()
+	s.ready(next, port)
 	return nil
 }
 
-func (s *externalStrategy) Restart() error {
+// handOver swaps in the binary on disk for Config.ProxyOnRebuild while the
+// current process keeps serving AppPort: the binary starts on an internal port
+// (passed as -port and PORT) and, once ready, a front proxy takes AppPort over
+// from the previous process and forwards to it. Later swaps run through the
+// proxy as in ProxyMode. A binary that fails on startup is reported with the
+// previous process left serving. It reports false, also with the previous
+// process untouched, if the binary cannot use the port it was given; later
+// swaps then stop the previous process first.
+func (s *externalStrategy) handOver() (bool, error) {
+	port, err := freeLocalPort()
+	if err != nil {
+		return false, nil
+	}
+	proxy := newFrontProxy(s.handler, s.overlay)
+	s.setProxy(proxy) // the binary is told its port from now on
+
+	next := s.newProcess(port)
+	ignoresPort := false
+	err = next.Start()
+	if err == nil {
+		s.processStarted(next)
+		if err = s.waitReady(next, port); err != nil {
+			// Alive on a port of its own, or unable to use the one it was given
+			ignoresPort = next.Running() || rejectsPort(err)
+			next.Stop(s.handler.StopGracePeriod)
+		}
+	}
+	if err != nil {
+		s.setProxy(nil)
+		if !ignoresPort {
+			s.restoreLastGood()
+			return true, err
+		}
+		s.direct = true
+		s.handler.Logger("New build does not listen on the port it was given, stopping the previous server first:", err)
+		return false, nil
+	}
+
+	// Requests are refused only between the previous process closing its
+	// listener and the proxy binding the port
+	prev, appPort := s.process, s.port
+	proxy.Release(port)
+	stopped := make(chan error, 1)
+	go func() { stopped <- prev.Stop(s.handler.StopGracePeriod) }()
+	err = proxy.Take(appPort, s.handler.StopGracePeriod+time.Second)
+	<-stopped
+	if err != nil {
+		next.Stop(s.handler.StopGracePeriod)
+		s.setProxy(nil)
+		return true, err
+	}
+
+	s.mu.Lock()
+	s.process, s.port = next, port
+	s.mu.Unlock()
+	s.supervise(next)
+	s.saveLastGood()
+	s.handler.logEvent(slog.LevelInfo, "AppPort handed over to the proxy, the previous build keeps serving during later rebuilds",
+		LogKeyMode, ModeExternal, LogKeyPort, appPort, LogKeyPID, next.PID())
+	s.ready(next, port)
+	return true, nil
+}
+
+// rejectsPort reports whether err shows a binary failing to bind a port in use
+// or rejecting the -port flag.
+func rejectsPort(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "address already in use") ||
+		strings.Contains(msg, "Only one usage of each socket address") ||
+		strings.Contains(msg, "flag provided but not defined: -port")
+}
+
+// setProxy replaces the front proxy. Must be called with s.runMu held.
+func (s *externalStrategy) setProxy(p *frontProxy) {
+	s.mu.Lock()
+	s.proxy = p
+	s.mu.Unlock()
+}
+
+func (s *externalStrategy) processStarted(p *serverProcess) {
+	s.handler.emit(Event{Type: EventProcessStarted, PID: p.PID(), BuildID: s.buildID})
+}
+
+// ready reports p serving on port, or on the proxy port in ProxyMode.
+func (s *externalStrategy) ready(p *serverProcess, port string) {
+	if s.proxy != nil {
+		port = s.handler.Port()
+	}
+	s.handler.emit(Event{Type: EventReady, Mode: ModeExternal, PID: p.PID(), Port: port})
+}
+
+// buildFailed records a failed compile or start. The previous process keeps
+// serving if there is one, otherwise the error page takes its place.
+func (s *externalStrategy) buildFailed(err error) {
+	s.mu.Lock()
+	s.status.LastError = err
+	s.status.FailedAt = time.Now()
+	s.mu.Unlock()
+
+	s.handler.emit(Event{
+		Type:        EventBuildFailed,
+		Err:         err,
+		Diagnostics: parseDiagnostics(err.Error(), filepath.Join(s.handler.AppRootDir, s.handler.OutputDir)),
+	})
+
+	if s.process.Running() {
+		s.handler.logEvent(slog.LevelWarn, "Build failed, keeping the previous server: "+s.buildStatus().String(),
+			LogKeyPID, s.process.PID(), LogKeyBuild, s.buildID, LogKeyError, err)
+		return
+	}
+	s.showBuildError(err)
+}
+
+// reuseBuild reports whether the last build still matches its inputs, so the
+// compile can be skipped for the changed paths. If the process is not running
+// it is restarted from the existing binary. A stopped strategy has nothing to
+// rebuild, so it reports true as well. Inputs read for the check are returned
+// for the compile.
+func (s *externalStrategy) reuseBuild(paths []string) (bool, *buildInputs, error) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	if s.stopped {
+		s.handler.Logger("Rebuild skipped: server stopped")
+		return true, nil, nil
+	}
+	if s.built.hash == "" {
+		s.handler.Logger("Rebuilding: no successful build to reuse")
+		return false, nil, nil
+	}
+	for _, p := range paths {
+		if !s.built.Contains(p) {
+			s.handler.Logger("Rebuilding:", p, "is not a tracked input of the server package")
+			return false, nil, nil
+		}
+	}
+
+	current, err := s.handler.readBuildInputs()
+	if err != nil {
+		s.handler.Logger("Rebuilding: reading server inputs:", err)
+		return false, nil, nil
+	}
+	if current.hash != s.built.hash {
+		s.handler.Logger("Rebuilding: server inputs changed")
+		return false, &current, nil
+	}
+
+	s.mu.Lock()
+	builtAt, took := s.status.BuiltAt, s.status.BuildTook
+	s.mu.Unlock()
+
+	if s.process.Running() {
+		// Sources are back to the running build, it is no longer stale
+		s.mu.Lock()
+		s.status.LastError, s.status.FailedAt = nil, time.Time{}
+		s.mu.Unlock()
+		s.overlay.Clear()
+		s.handler.Logger("Rebuild skipped: server inputs unchanged since last build")
+		return true, nil, nil
+	}
+	s.handler.Logger("Recompile skipped: server inputs unchanged, restarting process")
+	return true, nil, s.launch(builtAt, took)
+}
+
+// showBuildError serves the error page while no server is running.
+func (s *externalStrategy) showBuildError(err error) {
+	s.showError("Build failed", err)
+}
+
+// showError serves a page with title and err on AppPort.
+func (s *externalStrategy) showError(title string, err error) {
+	s.overlay.Set(title, err.Error())
+
+	if s.proxy != nil {
+		s.proxy.Fail(err)
+		return
+	}
+
+	if err := s.overlay.Listen(); err != nil {
+		s.handler.Logger("Serving error page:", err)
+	}
+}
+
+// Run compiles and starts the binary, then serves until ctx is cancelled or
+// the strategy is stopped. Cancelling ctx also aborts a compile in progress.
+// A failed build does not end Run: the error page is served until a file
+// event fixes it. Unlike Start it ignores Config.ExitChan.
+func (s *externalStrategy) Run(ctx context.Context) error {
+	s.resume()
+	done := make(chan struct{})
+	s.mu.Lock()
+	s.done = done
+	s.mu.Unlock()
+
+	if s.proxy != nil {
+		if err := s.proxy.Start(); err != nil {
+			return errors.Join(errors.New("starting proxy"), err)
+		}
+	}
+
+	stopCompile := context.AfterFunc(ctx, func() { s.goCompiler.Cancel() })
+	s.startServer(nil) // logged and reported by buildFailed
+	stopCompile()
+
+	select {
+	case <-ctx.Done():
+		sctx, cancel := s.handler.shutdownContext(ctx)
+		defer cancel()
+		return s.Shutdown(sctx)
+	case <-done:
+		return nil
+	}
+}
+
+// Stop terminates the running binary (SIGTERM, then SIGKILL after StopGracePeriod)
+// and returns once AppPort is free. It never touches Config.ExitChan.
+func (s *externalStrategy) Stop() error {
+	// No deadline, every step gets StopGracePeriod
+	return s.Shutdown(context.Background())
+}
+
+// Shutdown stops the binary like Stop, but within the ctx deadline: the binary
+// is killed and the proxy connections are closed when it expires.
+func (s *externalStrategy) Shutdown(ctx context.Context) error {
+	s.debouncer.Stop()
+	s.goCompiler.Cancel()
+
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	wasStopped := s.stopped
+	s.stopped = true
+	s.stopCrashRestarts()
+
+	s.mu.Lock()
+	if s.unwatch != nil {
+		close(s.unwatch)
+		s.unwatch = nil
+		s.watching = nil
+	}
+	if s.done != nil {
+		close(s.done)
+		s.done = nil
+	}
+	s.mu.Unlock()
+
+	// Every step runs even if an earlier one failed, e.g. on an expired deadline
+	wasRunning, pid := s.process.Running(), s.process.PID()
+	err := s.stopProcess(gracePeriod(ctx, s.handler.StopGracePeriod))
+	if s.proxy != nil {
+		pctx, cancel := context.WithTimeout(ctx, s.handler.StopGracePeriod)
+		err = errors.Join(err, s.proxy.Stop(pctx))
+		cancel()
+	}
+	err = errors.Join(err, s.overlay.Clear())
+	if wasRunning {
+		s.handler.logEvent(slog.LevelInfo, "External Server stopped", LogKeyMode, ModeExternal, LogKeyPID, pid)
+	}
+	if !wasStopped {
+		s.handler.emit(Event{Type: EventStopped, Mode: ModeExternal})
+	}
+	return err
+}
+
+// stopProcess ends the current run (if any), killing it after grace, and waits
+// up to grace until it released its port.
+func (s *externalStrategy) stopProcess(grace time.Duration) error {
+	if !s.process.Running() {
+		return nil
+	}
+	if err := s.process.Stop(grace); err != nil {
+		return err
+	}
+	return waitPortFree(s.port, grace)
+}
+
+// isIgnoredRestartError reports errors caused by cancelling a build or stopping
+// the process on purpose, which are not worth reporting.
+func isIgnoredRestartError(err error) bool {
 	ignoreError := []string{
 		"signal: killed",
 		"signal: interrupt",
 	}
+	for _, v := range ignoreError {
+		if strings.Contains(err.Error(), v) {
+			return true
+		}
+	}
+	return false
+}
 
-	err := s.startServer()
+func (s *externalStrategy) Restart() error {
+	return s.restart(nil)
+}
+
+// restart is Restart with the build inputs already read, see startServer.
+func (s *externalStrategy) restart(read *buildInputs) error {
+	err := s.startServer(read)
 	if err != nil {
-		shouldIgnore := false
-		for _, v := range ignoreError {
-			if strings.Contains(err.Error(), v) {
-				shouldIgnore = true
-				break
-			}
-		}
-		if !shouldIgnore {
+		if !isIgnoredRestartError(err) {
 			s.handler.Logger(err)
 		}
 		return err
@@ -273,16 +942,77 @@ func (s *externalStrategy) Restart() error {
 	return nil
 }
 
+// HandleFileEvent restarts the server when a file is created, written, removed
+// or renamed (a rename reports the old path, the new one arrives as create).
+// With Config.DebounceDelay the restart runs in the background once events
+// stop arriving, and nil is returned.
 func (s *externalStrategy) HandleFileEvent(fileName, extension, filePath, event string) error {
-	if event == "write" {
-		s.handler.Logger("Go file modified, restarting external server ...")
-		err := s.Restart()
-		if err != nil {
+	switch event {
+	case "create", "write", "remove", "rename":
+	default:
+		return nil
+	}
+	if !s.affects(filePath) {
+		s.handler.Logger("Ignored", filePath+": not part of the server build")
+		return nil
+	}
+	if s.handler.DebounceDelay > 0 {
+		s.debouncer.Trigger(filePath)
+		return nil
+	}
+	return s.rebuild([]string{filePath})
+}
+
+// affects reports whether filePath belongs to the packages or embed patterns of
+// the server binary. Everything counts while the dependencies are unknown.
+// The set is refreshed on every compile, so changed imports and go.mod edits
+// (which always affect the build) are picked up.
+func (s *externalStrategy) affects(filePath string) bool {
+	s.mu.Lock()
+	deps := s.deps
+	s.mu.Unlock()
+	return deps.hash == "" || deps.Affects(filePath)
+}
+
+// builtFrom reports whether filePath is known to be part of the server build,
+// e.g. a template embedded with //go:embed. Unlike affects it is false while
+// the dependencies are unknown.
+func (s *externalStrategy) builtFrom(filePath string) bool {
+	s.mu.Lock()
+	deps := s.deps
+	s.mu.Unlock()
+	return deps.hash != "" && deps.Affects(filePath)
+}
+
+// embedExtensions returns the extensions of the files embedded in the binary,
+// known once a compile started. It is a watcher query, so it never runs go list.
+func (s *externalStrategy) embedExtensions() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.deps.exts
+}
+
+// rebuild recompiles and restarts the server for the changed paths, unless
+// their content matches the last build. If the main input file is gone the
+// handler falls back to in-memory mode instead.
+func (s *externalStrategy) rebuild(paths []string) error {
+	mainFile := filepath.Join(s.handler.AppRootDir, s.handler.MainInputFileRelativePath())
+	if _, err := os.Stat(mainFile); errors.Is(err, os.ErrNotExist) {
+		return s.handler.fallbackToInMemory(s)
+	}
+
+	s.handler.Logger("Go file modified, restarting external server ...")
+	reused, read, err := s.reuseBuild(paths)
+	if reused {
+		return err
+	}
+	err = s.restart(read)
+	if err != nil {
+		if !isIgnoredRestartError(err) {
 			s.handler.Logger("RestartServer failed:", err)
-		} else {
-			s.handler.Logger("RestartServer succeeded")
 		}
-		return err
+	} else {
+		s.handler.Logger("RestartServer succeeded")
 	}
-	return nil
+	return err
 }
@@ -1,12 +1,17 @@
 package server
 
-import "errors"
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
 
-// CreateTemplateServer switches from In-Memory to External mode.
+// CreateTemplateServer switches from In-Memory (or a registered strategy) to External mode.
 // It generates the server files (if not present), compiles, and runs them.
 // This implements the transition from "In-Memory" to "Permanent" (External) mode.
 func (h *ServerHandler) CreateTemplateServer(progress chan<- string) error {
-	if !h.inMemory {
+	if h.currentMode() == ModeExternal {
 		if progress != nil {
 			progress <- "Server is already in external mode."
 		}
@@ -14,11 +19,11 @@ func (h *ServerHandler) CreateTemplateServer(progress chan<- string) error {
 	}
 
 	if progress != nil {
-		progress <- "Stopping In-Memory Server..."
+		progress <- "Stopping current server..."
 	}
-	// Stop the current in-memory server
-	if err := h.strategy.Stop(); err != nil {
-		return errors.Join(errors.New("failed to stop in-memory server"), err)
+	// Stop the current in-memory or registered strategy
+	if err := h.current().Stop(); err != nil {
+		return errors.Join(errors.New("failed to stop current server"), err)
 	}
 
 	if progress != nil {
@@ -28,20 +33,28 @@ func (h *ServerHandler) CreateTemplateServer(progress chan<- string) error {
 	if err := h.generateServerFromEmbeddedMarkdown(); err != nil {
 		return errors.Join(errors.New("failed to generate server files"), err)
 	}
+	if h.CopyWasmExec {
+		if progress != nil {
+			progress <- "Copying wasm_exec.js..."
+		}
+		// Not fatal, the app may still work with the copy already in PublicDir
+		if err := h.copyWasmExec(); err != nil {
+			h.logEvent(slog.LevelWarn, "Copying wasm_exec.js failed", LogKeyError, err)
+		}
+	}
 
 	if progress != nil {
 		progress <- "Switching to External Process Strategy..."
 	}
 	// Switch strategy state
-	h.inMemory = false
-	h.strategy = newExternalStrategy(h)
+	external := h.useStrategy(ModeExternal)
 
 	if progress != nil {
 		progress <- "Starting External Server..."
 	}
 	// Start the new external server (compiles and runs)
 	// We pass nil for wg because this is a runtime transition, not application startup
-	if err := h.strategy.Start(nil); err != nil {
+	if err := external.Start(nil); err != nil {
 		// If start fails, should we try to revert?
 		// For now, return the error.
 		return errors.Join(errors.New("failed to start external server"), err)
@@ -52,3 +65,53 @@ func (h *ServerHandler) CreateTemplateServer(progress chan<- string) error {
 	}
 	return nil
 }
+
+// isMainInputFile reports whether path is the external server's main input file.
+func (h *ServerHandler) isMainInputFile(path string) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	main, err := filepath.Abs(filepath.Join(h.AppRootDir, h.MainInputFileRelativePath()))
+	return err == nil && abs == main
+}
+
+// fallbackToInMemory stops the external strategy s after its main input file was
+// deleted and serves Config.Routes in memory until the file is created again.
+func (h *ServerHandler) fallbackToInMemory(s ServerStrategy) error {
+	if h.current() != s {
+		return nil // already switched
+	}
+	h.logEvent(slog.LevelWarn, "Main input file removed, falling back to In-Memory Server Mode",
+		LogKeyMode, ModeInMemory, LogKeyFile, h.MainInputFileRelativePath())
+
+	if err := s.Stop(); err != nil {
+		return errors.Join(errors.New("failed to stop external server"), err)
+	}
+
+	h.mainInputRemoved.Store(true)
+	h.useStrategy(ModeInMemory)
+	// In-memory Start blocks until ExitChan fires
+	go h.StartServer(nil)
+	return nil
+}
+
+// restoreExternalMode goes back to the external server once the main input
+// file removed earlier exists again.
+func (h *ServerHandler) restoreExternalMode() error {
+	if _, err := os.Stat(filepath.Join(h.AppRootDir, h.MainInputFileRelativePath())); err != nil {
+		return nil
+	}
+	h.logEvent(slog.LevelInfo, "Main input file is back, restoring External Server Mode",
+		LogKeyMode, ModeExternal, LogKeyFile, h.MainInputFileRelativePath())
+
+	if err := h.current().Stop(); err != nil {
+		return errors.Join(errors.New("failed to stop in-memory server"), err)
+	}
+
+	h.mainInputRemoved.Store(false)
+	if err := h.useStrategy(ModeExternal).Start(nil); err != nil {
+		return errors.Join(errors.New("failed to start external server"), err)
+	}
+	return nil
+}
@@ -1,5 +1,10 @@
 package server
 
+import (
+	"log/slog"
+	"strings"
+)
+
 // Store defines the minimal interface for persistent storage
 type Store interface {
 	Get(key string) (string, error)
@@ -13,11 +18,16 @@ type UI interface {
 
 const StoreKeyExternalServer = "server_external_mode"
 
+// StoreKeyServerStrategy holds the name of the strategy chosen through
+// ServerModeHandler, see ServerHandler.SetStrategy.
+const StoreKeyServerStrategy = "server_strategy"
+
 type ServerModeHandler struct {
-	h   *ServerHandler
-	db  Store
-	ui  UI
-	log func(message ...any)
+	h    *ServerHandler
+	db   Store
+	ui   UI
+	log  func(message ...any)
+	slog *slog.Logger // set by SetSlogHandler
 }
 
 func NewServerModeHandler(h *ServerHandler, db Store, ui UI) *ServerModeHandler {
@@ -32,47 +42,85 @@ func (s *ServerModeHandler) SetLog(f func(message ...any)) {
 	s.log = f
 }
 
+// SetSlogHandler sends the mode switch records to handler, see ServerHandler.SetSlogHandler.
+func (s *ServerModeHandler) SetSlogHandler(handler slog.Handler) {
+	s.slog = slog.New(handler)
+}
+
 func (s *ServerModeHandler) Name() string {
 	return "ServerMode"
 }
 
+// Label shows the mode the handler is actually running in, see ServerHandler.Status.
 func (s *ServerModeHandler) Label() string {
-	external := false
-	if val, err := s.db.Get(StoreKeyExternalServer); err == nil && val == "true" {
-		external = true
-	}
-
-	if external {
+	switch mode := s.h.Status().Mode; mode {
+	case ModeExternal:
 		return "SERVER: EXTERNAL"
+	case ModeInMemory:
+		return "SERVER: INTERNAL"
+	default:
+		return "SERVER: " + strings.ToUpper(mode)
 	}
-	return "SERVER: INTERNAL"
 }
 
+// Execute toggles between In-Memory and External mode and stores the new one.
+// From a custom strategy it goes back to In-Memory.
 func (s *ServerModeHandler) Execute() {
-	external := "false"
-	if val, err := s.db.Get(StoreKeyExternalServer); err == nil && val == "true" {
-		external = "true"
+	isExternal := s.h.Status().Mode == ModeInMemory
+	s.h.SetExternalServerMode(isExternal)
+
+	msg, mode := "Switched to Internal Server Mode", ModeInMemory
+	if isExternal {
+		msg, mode = "Switched to External Server Mode", ModeExternal
 	}
+	s.switched(msg, mode)
+}
 
-	// Toggle
-	if external == "true" {
-		external = "false"
-	} else {
-		external = "true"
+// SetStrategy switches to the strategy registered under name and stores it.
+func (s *ServerModeHandler) SetStrategy(name string) error {
+	if err := s.h.SetStrategy(name); err != nil {
+		return err
 	}
+	s.switched("Switched to "+name+" strategy", name)
+	return nil
+}
 
-	s.db.Set(StoreKeyExternalServer, external)
+// Restore switches to the strategy saved by Execute or SetStrategy, if any.
+// Stores written before strategies were named only hold StoreKeyExternalServer.
+func (s *ServerModeHandler) Restore() error {
+	name, err := s.db.Get(StoreKeyServerStrategy)
+	if err != nil || name == "" {
+		if val, err := s.db.Get(StoreKeyExternalServer); err != nil || val != "true" {
+			return nil
+		}
+		name = ModeExternal
+	}
+	if name == s.h.Status().Mode {
+		return nil
+	}
+	if err := s.h.SetStrategy(name); err != nil {
+		return err
+	}
+	if s.ui != nil {
+		s.ui.RefreshUI()
+	}
+	return nil
+}
 
-	// Update handler
-	isExternal := (external == "true")
-	s.h.SetExternalServerMode(isExternal)
+// switched stores mode, logs msg and refreshes the UI.
+func (s *ServerModeHandler) switched(msg, mode string) {
+	external := "false"
+	if mode != ModeInMemory {
+		external = "true"
+	}
+	s.db.Set(StoreKeyExternalServer, external)
+	s.db.Set(StoreKeyServerStrategy, mode)
 
 	if s.log != nil {
-		if isExternal {
-			s.log("Switched to External Server Mode")
-		} else {
-			s.log("Switched to Internal Server Mode")
-		}
+		slog.New(funcHandler{log: s.log}).Info(msg, LogKeyMode, mode)
+	}
+	if s.slog != nil {
+		s.slog.Info(msg, LogKeyMode, mode)
 	}
 
 	if s.ui != nil {
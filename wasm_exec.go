@@ -0,0 +1,78 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// wasmExecLocations are where GOROOT keeps wasm_exec.js, lib/wasm since Go 1.24.
+var wasmExecLocations = []string{
+	filepath.Join("lib", "wasm", "wasm_exec.js"),
+	filepath.Join("misc", "wasm", "wasm_exec.js"),
+}
+
+// wasmExecFile locates wasm_exec.js in the GOROOT of the go command gobuild
+// runs, as seen from AppRootDir (so a go.mod toolchain line applies). The
+// result is looked up once.
+func (h *ServerHandler) wasmExecFile() (string, error) {
+	h.wasmExecOnce.Do(func() {
+		cmd := exec.Command("go", "env", "GOROOT")
+		cmd.Dir = h.AppRootDir
+		out, err := cmd.Output()
+		if err != nil {
+			h.wasmExecErr = errors.Join(errors.New("go env GOROOT"), err)
+			return
+		}
+		goroot := string(bytes.TrimSpace(out))
+		for _, rel := range wasmExecLocations {
+			if file := filepath.Join(goroot, rel); fileExists(file) {
+				h.wasmExecPath = file
+				return
+			}
+		}
+		h.wasmExecErr = errors.New("wasm_exec.js not found in GOROOT " + goroot)
+	})
+	return h.wasmExecPath, h.wasmExecErr
+}
+
+// wasmExecHandler serves the toolchain's wasm_exec.js.
+func (h *ServerHandler) wasmExecHandler() http.Handler {
+	return noCache(gzipHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file, err := h.wasmExecFile()
+		if err != nil {
+			h.logEvent(slog.LevelWarn, "Serving wasm_exec.js", LogKeyError, err)
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+		http.ServeFile(w, r, file)
+	})))
+}
+
+// copyWasmExec writes the toolchain's wasm_exec.js into PublicDir, at the
+// place WasmExecPath points to, replacing an older copy.
+func (h *ServerHandler) copyWasmExec() error {
+	file, err := h.wasmExecFile()
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	target := filepath.Join(h.publicDirPath(), filepath.FromSlash(strings.TrimPrefix(h.WasmExecPath, "/")))
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(target, content, 0o644); err != nil {
+		return err
+	}
+	h.Logger("Copied", file, "to", target)
+	return nil
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWasmExecFromToolchain(t *testing.T) {
+	h := New(&Config{AppRootDir: t.TempDir()})
+	file, err := h.wasmExecFile()
+	if err != nil {
+		t.Skipf("no wasm_exec.js in this toolchain: %v", err)
+	}
+	want, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base, public := newStaticServer(t)
+	// A stale copy in PublicDir does not win over the toolchain file
+	if err := os.WriteFile(filepath.Join(public, "wasm_exec.js"), []byte("stale"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if status, body := getBody(t, base+"/wasm_exec.js"); status != 200 || body != string(want) {
+		t.Fatalf("expected toolchain wasm_exec.js, got %d with %d bytes", status, len(body))
+	}
+}
+
+func TestWasmExecPathIsConfigurable(t *testing.T) {
+	port := freePort(t)
+	exit := make(chan bool, 1)
+	h := New(&Config{AppRootDir: t.TempDir(), AppPort: port, WasmExecPath: "js/wasm_exec.js", ExitChan: exit})
+	if _, err := h.wasmExecFile(); err != nil {
+		t.Skipf("no wasm_exec.js in this toolchain: %v", err)
+	}
+	go h.StartServer(nil)
+	defer func() { exit <- true }()
+
+	if status, _ := getBody(t, "http://127.0.0.1:"+port+"/js/wasm_exec.js"); status != 200 {
+		t.Fatalf("expected wasm_exec.js on the configured path, got %d", status)
+	}
+}
+
+func TestCopyWasmExecIntoPublicDir(t *testing.T) {
+	tmp := t.TempDir()
+	h := New(&Config{AppRootDir: tmp, PublicDir: "web/public", WasmExecPath: "/js/wasm_exec.js"})
+	file, err := h.wasmExecFile()
+	if err != nil {
+		t.Skipf("no wasm_exec.js in this toolchain: %v", err)
+	}
+
+	target := filepath.Join(tmp, "web", "public", "js", "wasm_exec.js")
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(target, []byte("stale"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.copyWasmExec(); err != nil {
+		t.Fatal(err)
+	}
+
+	want, _ := os.ReadFile(file)
+	if got, _ := os.ReadFile(target); string(got) != string(want) {
+		t.Fatal("stale wasm_exec.js was not replaced by the toolchain one")
+	}
+}